@@ -0,0 +1,61 @@
+package kitty
+
+import (
+	"net/http"
+)
+
+// adminhandler is a handler served by the admin server.
+type adminhandler struct {
+	method, path string
+	handler      http.Handler
+}
+
+// AdminHandler registers a handler to the admin server (or to the main server if no admin port is configured).
+func (t *HTTPTransport) AdminHandler(method, path string, h http.Handler) *HTTPTransport {
+	t.adminhandlers = append(t.adminhandlers, adminhandler{method: method, path: path, handler: h})
+	return t
+}
+
+// AdminHTTPMiddlewares defines the list of HTTP middlewares to be added to all admin handlers
+// (health checks, pprof and handlers registered with AdminHandler).
+func (t *HTTPTransport) AdminHTTPMiddlewares(m ...func(http.Handler) http.Handler) *HTTPTransport {
+	t.adminhttpmiddleware = func(next http.Handler) http.Handler {
+		for i := len(m) - 1; i >= 0; i-- {
+			next = m[i](next)
+		}
+		return next
+	}
+	return t
+}
+
+// registerAdminHandlers registers health, pprof and admin handlers.
+// They are served by the admin server if an admin port is configured, by the main server otherwise.
+func (t *HTTPTransport) registerAdminHandlers() {
+	mux := t.mux
+	if t.cfg.AdminHTTPPort > 0 {
+		mux = t.adminmux
+	}
+	mux = &middlewareRouter{Router: mux, middleware: t.adminhttpmiddleware}
+
+	// register health handlers
+	mux.Handle("GET", t.cfg.LivenessCheckPath, t.liveness)
+	mux.Handle("GET", t.cfg.ReadinessCheckPath, http.HandlerFunc(t.readinessHandler))
+
+	// register pprof handlers
+	registerPProf(t.cfg, mux)
+
+	for _, h := range t.adminhandlers {
+		mux.Handle(h.method, h.path, h.handler)
+	}
+}
+
+// middlewareRouter wraps all handlers registered to a Router with a middleware.
+type middlewareRouter struct {
+	Router
+	middleware func(http.Handler) http.Handler
+}
+
+// Handle registers a handler, wrapped by the middleware, to the router.
+func (r *middlewareRouter) Handle(method, path string, h http.Handler) {
+	r.Router.Handle(method, path, r.middleware(h))
+}
@@ -0,0 +1,82 @@
+package kitty
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAdminServer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	exitError := make(chan error)
+	middlewareCalled := false
+	tr := NewHTTPTransport(Config{HTTPPort: 8085, AdminHTTPPort: 8084, AdminHTTPAddress: "127.0.0.1", EnablePProf: true}).
+		Endpoint("POST", "/foo", testEP, Decoder(goodDecoder)).
+		AdminHandler("GET", "/admin", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})).
+		AdminHTTPMiddlewares(func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				middlewareCalled = true
+				h.ServeHTTP(w, r)
+			})
+		})
+	go func() {
+		exitError <- NewServer(tr).Run(ctx)
+	}()
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+	start := time.Now()
+	for {
+		resp, err := client.Get("http://127.0.0.1:8084/alivez")
+		if err == nil && resp.StatusCode == http.StatusOK {
+			resp.Body.Close()
+			break
+		}
+		if time.Since(start) > 500*time.Millisecond {
+			t.Fatal("admin server did not start within 500msec or liveness returned an error")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if !middlewareCalled {
+		t.Error("the admin middleware was not called")
+	}
+
+	tcs := []struct {
+		url    string
+		status int
+	}{
+		{url: "http://127.0.0.1:8084/readyz", status: http.StatusOK},
+		{url: "http://127.0.0.1:8084/debug/pprof/cmdline", status: http.StatusOK},
+		{url: "http://127.0.0.1:8084/admin", status: http.StatusNoContent},
+		{url: "http://127.0.0.1:8084/foo", status: http.StatusNotFound},
+		{url: "http://localhost:8085/alivez", status: http.StatusNotFound},
+		{url: "http://localhost:8085/debug/pprof/cmdline", status: http.StatusNotFound},
+		{url: "http://localhost:8085/admin", status: http.StatusNotFound},
+	}
+	for _, tc := range tcs {
+		resp, err := client.Get(tc.url)
+		if err != nil {
+			t.Errorf("client.Get(%s) returned an error : %s", tc.url, err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Errorf("client.Get(%s) returned a %d status instead of %d", tc.url, resp.StatusCode, tc.status)
+		}
+	}
+
+	cancel()
+	select {
+	case <-time.After(time.Second):
+		t.Error("Server.Run has not stopped after 1sec")
+	case err := <-exitError:
+		if err != nil && err != context.Canceled {
+			t.Errorf("Server.Run returned an error : %s", err)
+		}
+	}
+	if _, err := client.Get("http://127.0.0.1:8084/alivez"); err == nil {
+		t.Error("the admin server should have been stopped")
+	}
+}
@@ -2,29 +2,169 @@ package backoff
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/cenkalti/backoff"
 	"github.com/go-kit/kit/endpoint"
 	"github.com/objenious/kitty"
 )
 
-// NewBackoff creates an exponential backoff middleware, based on github.com/cenkalti/backoff.
-// Retries will be attempted if the returned error implements is retryable (see kitty.IsRetryable).
-func NewBackoff(bo backoff.BackOff) endpoint.Middleware {
+// config holds the configuration of the backoff middleware.
+type config struct {
+	maxAttempts    int
+	attemptTimeout time.Duration
+	budget         *Budget
+	notify         NotifyFunc
+}
+
+// Option is an option for the backoff middleware.
+type Option func(*config) *config
+
+// MaxAttempts defines the maximum number of attempts, including the first one (default: no limit, until the BackOff stops).
+func MaxAttempts(n int) Option {
+	return func(c *config) *config {
+		c.maxAttempts = n
+		return c
+	}
+}
+
+// AttemptTimeout defines the maximum duration of each attempt (default: no timeout, apart from the context deadline).
+// An attempt that times out is retried.
+func AttemptTimeout(d time.Duration) Option {
+	return func(c *config) *config {
+		c.attemptTimeout = d
+		return c
+	}
+}
+
+// WithBudget defines a retry budget, that may be shared between middlewares calling the same dependency.
+func WithBudget(b *Budget) Option {
+	return func(c *config) *config {
+		c.budget = b
+		return c
+	}
+}
+
+// NotifyFunc is called before each retry, with the error of the failed attempt (starting at 1),
+// and the delay before the next attempt.
+type NotifyFunc func(ctx context.Context, err error, attempt int, delay time.Duration)
+
+// Notify defines the function called before each retry (default: LogRetry).
+// A nil function disables notifications.
+func Notify(fn NotifyFunc) Option {
+	return func(c *config) *config {
+		c.notify = fn
+		return c
+	}
+}
+
+// LogRetry logs retries with the logger of the context (see kitty.Logger).
+func LogRetry(ctx context.Context, err error, attempt int, delay time.Duration) {
+	_ = kitty.LogMessage(ctx, "retrying", "error", err, "attempt", attempt, "delay", delay)
+}
+
+// ErrUnsupportedBackOff is the panic value of NewBackoff, when called with a BackOff that can not be copied.
+// Use NewBackoffFunc instead.
+var ErrUnsupportedBackOff = errors.New("unsupported BackOff, use NewBackoffFunc")
+
+// NewBackoff creates a backoff middleware, based on github.com/cenkalti/backoff.
+// Retries will be attempted if the returned error is retryable (see kitty.IsRetryable), until bo stops,
+// the maximum number of attempts is reached, the retry budget is exhausted, or the context is done.
+// The delay before a retry is at least the delay requested by the Retry-After header of the error (see kitty.RetryAfter).
+// If the context has a deadline, no retry is attempted if the deadline would expire before the next attempt.
+//
+// As BackOff implementations are stateful, bo is copied for each call. Only the BackOff types of
+// github.com/cenkalti/backoff (ExponentialBackOff, with randomized, i.e. jittered, intervals, ConstantBackOff,
+// ZeroBackOff and StopBackOff) can be copied: NewBackoff panics with ErrUnsupportedBackOff with other implementations,
+// including wrappers like backoff.WithMaxRetries (use MaxAttempts, or NewBackoffFunc).
+func NewBackoff(bo backoff.BackOff, opts ...Option) endpoint.Middleware {
+	newBackOff := copier(bo)
+	if newBackOff == nil {
+		panic(ErrUnsupportedBackOff)
+	}
+	return NewBackoffFunc(newBackOff, opts...)
+}
+
+// NewBackoffFunc creates a backoff middleware like NewBackoff, calling newBackOff to get a new BackOff for each call.
+func NewBackoffFunc(newBackOff func() backoff.BackOff, opts ...Option) endpoint.Middleware {
+	cfg := &config{notify: LogRetry}
+	for _, opt := range opts {
+		cfg = opt(cfg)
+	}
 	return func(next endpoint.Endpoint) endpoint.Endpoint {
-		return func(ctx context.Context, request interface{}) (response interface{}, finalerr error) {
-			err := backoff.Retry(func() error {
-				response, finalerr = next(ctx, request)
-				if kitty.IsRetryable(finalerr) {
-					return finalerr
+		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+			b := newBackOff()
+			b.Reset()
+			if cfg.budget != nil {
+				cfg.budget.deposit()
+			}
+			for attempt := 1; ; attempt++ {
+				var timedout bool
+				response, timedout, err = cfg.attempt(ctx, next, request)
+				if err == nil || !(kitty.IsRetryable(err) || timedout) {
+					return response, err
+				}
+				if cfg.maxAttempts > 0 && attempt >= cfg.maxAttempts {
+					return response, err
+				}
+				delay := b.NextBackOff()
+				if delay == backoff.Stop {
+					return response, err
+				}
+				if ra, ok := kitty.RetryAfter(err); ok && ra > delay {
+					delay = ra
+				}
+				if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+					return response, err
+				}
+				if cfg.budget != nil && !cfg.budget.withdraw() {
+					return response, err
+				}
+				if cfg.notify != nil {
+					cfg.notify(ctx, err, attempt, delay)
+				}
+				timer := time.NewTimer(delay)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return response, err
+				case <-timer.C:
 				}
-				return nil
-			}, backoff.NewExponentialBackOff())
-
-			if err != nil {
-				finalerr = err
 			}
-			return
 		}
 	}
 }
+
+// attempt calls the endpoint, with the attempt timeout.
+// timedout is true if the attempt timed out, but the call is not done.
+func (cfg *config) attempt(ctx context.Context, next endpoint.Endpoint, request interface{}) (response interface{}, timedout bool, err error) {
+	if cfg.attemptTimeout <= 0 {
+		response, err = next(ctx, request)
+		return
+	}
+	actx, cancel := context.WithTimeout(ctx, cfg.attemptTimeout)
+	defer cancel()
+	response, err = next(actx, request)
+	timedout = err != nil && actx.Err() == context.DeadlineExceeded && ctx.Err() == nil
+	return
+}
+
+// copier returns a function copying bo, or nil if bo can not be copied.
+func copier(bo backoff.BackOff) func() backoff.BackOff {
+	switch b := bo.(type) {
+	case *backoff.ExponentialBackOff:
+		return func() backoff.BackOff {
+			cp := *b
+			return &cp
+		}
+	case *backoff.ConstantBackOff:
+		return func() backoff.BackOff {
+			cp := *b
+			return &cp
+		}
+	case *backoff.ZeroBackOff, *backoff.StopBackOff:
+		return func() backoff.BackOff { return b }
+	}
+	return nil
+}
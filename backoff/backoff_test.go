@@ -3,10 +3,18 @@ package backoff
 import (
 	"context"
 	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/cenkalti/backoff"
 	"github.com/go-kit/kit/endpoint"
+	kithttp "github.com/go-kit/kit/transport/http"
 	"github.com/objenious/kitty"
 )
 
@@ -58,3 +66,124 @@ func mkFailingEndpoint(errors ...error) endpoint.Endpoint {
 		return nil, err
 	}
 }
+
+func TestBackoffOptions(t *testing.T) {
+	bo := backoff.NewConstantBackOff(time.Millisecond)
+	{
+		var retries []int
+		e := NewBackoff(bo, MaxAttempts(3), Notify(func(_ context.Context, _ error, attempt int, _ time.Duration) {
+			retries = append(retries, attempt)
+		}))(mkFailingEndpoint(&retryableError{}, &retryableError{}, &retryableError{}))
+		_, err := e(context.TODO(), nil)
+		if err == nil {
+			t.Error("After the maximum number of attempts, backoff should return an error")
+		}
+		if !reflect.DeepEqual(retries, []int{1, 2}) {
+			t.Errorf("2 retries should have been notified, got %v", retries)
+		}
+	}
+	{
+		ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+		defer cancel()
+		e := NewBackoff(backoff.NewConstantBackOff(time.Second))(mkFailingEndpoint(&retryableError{}))
+		start := time.Now()
+		_, err := e(ctx, nil)
+		if err == nil || time.Since(start) > 500*time.Millisecond {
+			t.Error("backoff should not wait past the context deadline")
+		}
+	}
+	{
+		calls := 0
+		e := NewBackoff(bo, AttemptTimeout(10*time.Millisecond))(func(ctx context.Context, _ interface{}) (interface{}, error) {
+			calls++
+			if calls == 1 {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			return "OK", nil
+		})
+		res, err := e(context.TODO(), nil)
+		if err != nil || res != "OK" || calls != 2 {
+			t.Errorf("An attempt that timed out should be retried, got %v after %d calls", err, calls)
+		}
+	}
+	{
+		// each call adds 0.5 retry to the budget, with at most 1 retry available
+		calls := 0
+		e := NewBackoff(bo, WithBudget(NewBudget(0.5, 1)))(func(context.Context, interface{}) (interface{}, error) {
+			calls++
+			return nil, &retryableError{}
+		})
+		for i, expected := range []int{2, 1, 2} {
+			calls = 0
+			_, _ = e(context.TODO(), nil)
+			if calls != expected {
+				t.Errorf("call %d: the endpoint should have been called %d times, got %d", i, expected, calls)
+			}
+		}
+	}
+}
+
+func TestBackoffRetryAfter(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+	e := kitty.NewClient("GET", u, kithttp.EncodeJSONRequest, func(context.Context, *http.Response) (interface{}, error) {
+		return "OK", nil
+	}).Endpoint()
+	e = NewBackoff(backoff.NewConstantBackOff(time.Millisecond))(e)
+	start := time.Now()
+	if _, err := e(context.TODO(), nil); err != nil {
+		t.Fatalf("the second call should succeed, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("backoff should wait for the Retry-After delay, waited %s", elapsed)
+	}
+
+	calls = 0
+	ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := e(ctx, nil); err == nil || calls != 1 {
+		t.Error("backoff should not retry if the Retry-After delay exceeds the context deadline")
+	}
+}
+
+func TestBackoffFunc(t *testing.T) {
+	func() {
+		defer func() {
+			if p := recover(); p != ErrUnsupportedBackOff {
+				t.Errorf("NewBackoff should panic with a BackOff that can not be copied, got %v", p)
+			}
+		}()
+		NewBackoff(backoff.WithMaxRetries(backoff.NewConstantBackOff(time.Millisecond), 3))
+	}()
+
+	var calls int32
+	e := NewBackoffFunc(func() backoff.BackOff {
+		atomic.AddInt32(&calls, 1)
+		return backoff.WithMaxRetries(backoff.NewConstantBackOff(time.Millisecond), 3)
+	})(func(context.Context, interface{}) (interface{}, error) {
+		return nil, &retryableError{}
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = e(context.TODO(), nil)
+		}()
+	}
+	wg.Wait()
+	if atomic.LoadInt32(&calls) != 10 {
+		t.Errorf("a BackOff should be created for each call, got %d", calls)
+	}
+}
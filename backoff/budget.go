@@ -0,0 +1,40 @@
+package backoff
+
+import "sync"
+
+// Budget limits retries to a ratio of calls, so that a failing dependency is not overloaded by retries.
+// Each call adds ratio to the budget (up to burst), and each retry consumes 1.
+// A budget may be shared by several middlewares calling the same dependency.
+type Budget struct {
+	mu     sync.Mutex
+	tokens float64
+	ratio  float64
+	burst  float64
+}
+
+// NewBudget creates a retry budget, allowing ratio retries per call (e.g. 0.1 for 10%),
+// and up to burst retries when the budget is full (a new budget is full).
+func NewBudget(ratio float64, burst int) *Budget {
+	return &Budget{tokens: float64(burst), ratio: ratio, burst: float64(burst)}
+}
+
+// deposit adds a call to the budget.
+func (b *Budget) deposit() {
+	b.mu.Lock()
+	b.tokens += b.ratio
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.mu.Unlock()
+}
+
+// withdraw consumes a retry, and returns false if the budget is exhausted.
+func (b *Budget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
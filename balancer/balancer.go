@@ -0,0 +1,232 @@
+package balancer
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/sd"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/objenious/kitty"
+)
+
+// ErrNoInstance is returned when no instance is available. It is retryable.
+var ErrNoInstance = kitty.Unavailable(errors.New("no instance available"))
+
+// Instance is an instance of a load balanced service.
+type Instance struct {
+	// URL is the base URL of the instance (e.g. "http://10.0.0.1:8080").
+	URL string
+
+	endpoint    endpoint.Endpoint
+	outstanding int64
+
+	mu           sync.Mutex
+	failures     int
+	ejectedUntil time.Time
+}
+
+// Outstanding returns the number of pending requests of the instance.
+func (i *Instance) Outstanding() int64 {
+	return atomic.LoadInt64(&i.outstanding)
+}
+
+// ejected checks if the instance is ejected.
+func (i *Instance) ejected(now time.Time) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return now.Before(i.ejectedUntil)
+}
+
+// report records the result of a call. After maxFailures consecutive retryable errors, the instance is ejected.
+func (i *Instance) report(err error, maxFailures int, ejection time.Duration) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if !kitty.IsRetryable(err) {
+		i.failures = 0
+		return
+	}
+	i.failures++
+	if maxFailures > 0 && i.failures >= maxFailures {
+		i.failures = 0
+		i.ejectedUntil = time.Now().Add(ejection)
+	}
+}
+
+// Client is a load balanced kitty client, calling the instances provided by a go-kit sd.Instancer.
+// A static list of instances can be used with sd.FixedInstancer.
+type Client struct {
+	method, path string
+	enc          kithttp.EncodeRequestFunc
+	dec          kithttp.DecodeResponseFunc
+	options      []kithttp.ClientOption
+
+	policy      Policy
+	maxAttempts int
+	maxFailures int
+	ejection    time.Duration
+
+	instancer sd.Instancer
+	events    chan sd.Event
+	mu        sync.RWMutex
+	instances []*Instance
+}
+
+// NewClient creates a load balanced kitty client, calling path on the instances provided by instancer
+// (e.g. sd.FixedInstancer{"http://10.0.0.1:8080", "http://10.0.0.2:8080"}).
+// Unless specified, instances are selected in turn, a failed call is attempted on up to 2 other instances,
+// and an instance is ejected for 30s after 5 consecutive retryable errors.
+func NewClient(
+	method string,
+	instancer sd.Instancer,
+	path string,
+	enc kithttp.EncodeRequestFunc,
+	dec kithttp.DecodeResponseFunc,
+	options ...kithttp.ClientOption,
+) *Client {
+	c := &Client{
+		method:      method,
+		path:        path,
+		enc:         enc,
+		dec:         dec,
+		options:     options,
+		policy:      RoundRobin(),
+		maxAttempts: 3,
+		maxFailures: 5,
+		ejection:    30 * time.Second,
+		instancer:   instancer,
+		events:      make(chan sd.Event, 1),
+	}
+	instancer.Register(c.events)
+	// most instancers send the current instances when registering
+	select {
+	case ev := <-c.events:
+		c.update(ev)
+	default:
+	}
+	go c.receive()
+	return c
+}
+
+// Policy defines the load balancing policy (default: RoundRobin).
+func (c *Client) Policy(p Policy) *Client {
+	c.policy = p
+	return c
+}
+
+// MaxAttempts defines the maximum number of instances called, if calls fail with a retryable error (default: 3).
+func (c *Client) MaxAttempts(n int) *Client {
+	c.maxAttempts = n
+	return c
+}
+
+// Ejection defines after how many consecutive retryable errors (0 to disable ejection) an instance is ejected,
+// and for how long (default: 5 errors, 30s).
+// If all instances are ejected, calls are sent to all instances.
+func (c *Client) Ejection(maxFailures int, d time.Duration) *Client {
+	c.maxFailures = maxFailures
+	c.ejection = d
+	return c
+}
+
+// Close stops receiving instance updates.
+func (c *Client) Close() {
+	c.instancer.Deregister(c.events)
+	close(c.events)
+}
+
+// receive updates instances.
+func (c *Client) receive() {
+	for ev := range c.events {
+		c.update(ev)
+	}
+}
+
+// update updates the list of instances, keeping the state of existing instances.
+// On errors, the previous instances are kept.
+func (c *Client) update(ev sd.Event) {
+	if ev.Err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	existing := make(map[string]*Instance, len(c.instances))
+	for _, inst := range c.instances {
+		existing[inst.URL] = inst
+	}
+	instances := make([]*Instance, 0, len(ev.Instances))
+	for _, instURL := range ev.Instances {
+		if inst, ok := existing[instURL]; ok {
+			instances = append(instances, inst)
+			continue
+		}
+		u, err := url.Parse(strings.TrimSuffix(instURL, "/") + c.path)
+		if err != nil {
+			continue
+		}
+		instances = append(instances, &Instance{
+			URL:      instURL,
+			endpoint: kitty.NewClient(c.method, u, c.enc, c.dec, c.options...).Endpoint(),
+		})
+	}
+	c.instances = instances
+}
+
+// Instances returns the list of instances.
+func (c *Client) Instances() []*Instance {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]*Instance{}, c.instances...)
+}
+
+// pick selects an instance, excluding already called instances and ejected instances.
+func (c *Client) pick(ctx context.Context, request interface{}, called map[*Instance]bool) *Instance {
+	now := time.Now()
+	var available, ejected []*Instance
+	for _, inst := range c.Instances() {
+		switch {
+		case called[inst]:
+		case inst.ejected(now):
+			ejected = append(ejected, inst)
+		default:
+			available = append(available, inst)
+		}
+	}
+	if len(available) == 0 && len(called) == 0 {
+		available = ejected
+	}
+	if len(available) == 0 {
+		return nil
+	}
+	return c.policy.Pick(ctx, request, available)
+}
+
+// Endpoint returns a usable endpoint that invokes the selected instance.
+// If the call fails with a retryable error, another instance is called.
+func (c *Client) Endpoint() endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		called := map[*Instance]bool{}
+		err := ErrNoInstance
+		for attempt := 0; attempt < c.maxAttempts || attempt == 0; attempt++ {
+			inst := c.pick(ctx, request, called)
+			if inst == nil {
+				break
+			}
+			called[inst] = true
+			atomic.AddInt64(&inst.outstanding, 1)
+			var response interface{}
+			response, err = inst.endpoint(ctx, request)
+			atomic.AddInt64(&inst.outstanding, -1)
+			inst.report(err, c.maxFailures, c.ejection)
+			if err == nil || !kitty.IsRetryable(err) || ctx.Err() != nil {
+				return response, err
+			}
+		}
+		return nil, err
+	}
+}
@@ -0,0 +1,146 @@
+package balancer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/sd"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/objenious/kitty"
+)
+
+type testServers struct {
+	mu      sync.Mutex
+	calls   map[string]int
+	failing map[string]bool
+	urls    []string
+	servers []*httptest.Server
+}
+
+func newTestServers(n int) *testServers {
+	ts := &testServers{calls: map[string]int{}, failing: map[string]bool{}}
+	for i := 0; i < n; i++ {
+		var u string
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ts.mu.Lock()
+			ts.calls[u]++
+			failing := ts.failing[u]
+			ts.mu.Unlock()
+			if failing {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte(u))
+		}))
+		u = s.URL
+		ts.urls = append(ts.urls, u)
+		ts.servers = append(ts.servers, s)
+	}
+	return ts
+}
+
+func (ts *testServers) close() {
+	for _, s := range ts.servers {
+		s.Close()
+	}
+}
+
+func (ts *testServers) reset() {
+	ts.mu.Lock()
+	ts.calls = map[string]int{}
+	ts.mu.Unlock()
+}
+
+func decodeURL(_ context.Context, resp *http.Response) (interface{}, error) {
+	b := make([]byte, 64)
+	n, _ := resp.Body.Read(b)
+	return string(b[:n]), nil
+}
+
+func newTestClient(ts *testServers) *Client {
+	return NewClient("GET", sd.FixedInstancer(ts.urls), "/foo", kithttp.EncodeJSONRequest, decodeURL)
+}
+
+func TestRoundRobin(t *testing.T) {
+	ts := newTestServers(3)
+	defer ts.close()
+	e := newTestClient(ts).Endpoint()
+	for i := 0; i < 6; i++ {
+		if _, err := e(context.TODO(), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, u := range ts.urls {
+		if ts.calls[u] != 2 {
+			t.Errorf("each instance should have been called twice, got %v", ts.calls)
+		}
+	}
+}
+
+func TestRetryAndEjection(t *testing.T) {
+	ts := newTestServers(3)
+	defer ts.close()
+	ts.failing[ts.urls[0]] = true
+	e := newTestClient(ts).Ejection(2, time.Minute).Endpoint()
+	for i := 0; i < 6; i++ {
+		res, err := e(context.TODO(), nil)
+		if err != nil || res == ts.urls[0] {
+			t.Fatalf("failed calls should be retried on another instance, got %v/%v", res, err)
+		}
+	}
+	if ts.calls[ts.urls[0]] != 2 {
+		t.Errorf("the failing instance should have been ejected after 2 errors, got %d calls", ts.calls[ts.urls[0]])
+	}
+
+	for _, u := range ts.urls {
+		ts.failing[u] = true
+	}
+	ts.reset()
+	_, err := e(context.TODO(), nil)
+	if !kitty.IsRetryable(err) {
+		t.Errorf("a retryable error should be returned when all instances fail, got %v", err)
+	}
+	if ts.calls[ts.urls[0]] != 0 || ts.calls[ts.urls[1]] != 1 || ts.calls[ts.urls[2]] != 1 {
+		t.Errorf("each available instance should have been called once, got %v", ts.calls)
+	}
+}
+
+func TestConsistentHash(t *testing.T) {
+	ts := newTestServers(3)
+	defer ts.close()
+	key := func(_ context.Context, request interface{}) string { return request.(string) }
+	c := newTestClient(ts).Policy(ConsistentHash(key))
+	e := c.Endpoint()
+	selected := map[string]interface{}{}
+	for i := 0; i < 3; i++ {
+		for _, k := range []string{"a", "b", "c", "d"} {
+			res, err := e(context.TODO(), k)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if prev, ok := selected[k]; ok && prev != res {
+				t.Errorf("calls with the key %s should be handled by the same instance", k)
+			}
+			selected[k] = res
+		}
+	}
+}
+
+func TestLeastOutstanding(t *testing.T) {
+	instances := []*Instance{{URL: "a", outstanding: 3}, {URL: "b", outstanding: 1}, {URL: "c", outstanding: 2}}
+	if inst := LeastOutstanding().Pick(context.TODO(), nil, instances); inst.URL != "b" {
+		t.Errorf("the instance with the least outstanding requests should be selected, got %s", inst.URL)
+	}
+}
+
+func TestNoInstance(t *testing.T) {
+	c := NewClient("GET", sd.FixedInstancer{}, "/", kithttp.EncodeJSONRequest, decodeURL)
+	defer c.Close()
+	if _, err := c.Endpoint()(context.TODO(), nil); err != ErrNoInstance {
+		t.Errorf("ErrNoInstance should be returned, got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package balancer
+
+import (
+	"context"
+	"hash/fnv"
+	"sync/atomic"
+)
+
+// Policy selects the instance handling a call.
+type Policy interface {
+	// Pick selects an instance among available instances (there is at least one).
+	Pick(ctx context.Context, request interface{}, instances []*Instance) *Instance
+}
+
+type roundRobin struct {
+	next uint64
+}
+
+// RoundRobin creates a policy selecting instances in turn.
+func RoundRobin() Policy {
+	return &roundRobin{}
+}
+
+// Pick implements Policy.
+func (p *roundRobin) Pick(_ context.Context, _ interface{}, instances []*Instance) *Instance {
+	n := atomic.AddUint64(&p.next, 1) - 1
+	return instances[n%uint64(len(instances))]
+}
+
+type leastOutstanding struct{}
+
+// LeastOutstanding creates a policy selecting the instance with the least outstanding requests.
+func LeastOutstanding() Policy {
+	return leastOutstanding{}
+}
+
+// Pick implements Policy.
+func (leastOutstanding) Pick(_ context.Context, _ interface{}, instances []*Instance) *Instance {
+	selected := instances[0]
+	for _, inst := range instances[1:] {
+		if inst.Outstanding() < selected.Outstanding() {
+			selected = inst
+		}
+	}
+	return selected
+}
+
+// KeyFunc returns the key of a call (e.g. a user id), used by the ConsistentHash policy.
+type KeyFunc func(ctx context.Context, request interface{}) string
+
+type consistentHash struct {
+	key KeyFunc
+}
+
+// ConsistentHash creates a policy selecting instances by key: calls with the same key are handled by the same instance,
+// and only the calls of an instance are moved when it is added or removed (rendezvous hashing).
+func ConsistentHash(key KeyFunc) Policy {
+	return consistentHash{key: key}
+}
+
+// Pick implements Policy.
+func (p consistentHash) Pick(ctx context.Context, request interface{}, instances []*Instance) *Instance {
+	key := p.key(ctx, request)
+	var selected *Instance
+	var max uint64
+	for _, inst := range instances {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(inst.URL))
+		_, _ = h.Write([]byte(key))
+		if score := h.Sum64(); selected == nil || score > max {
+			selected, max = inst, score
+		}
+	}
+	return selected
+}
@@ -15,23 +15,29 @@ type cbResponse struct {
 
 // NewCircuitBreaker creates a circuit breaker middleware, based on github.com/sony/gobreaker.
 // CircuitBreaker will only trigger on retryable errors (see kitty.IsRetryable).
+// To use a circuit breaker per host or tenant, see Registry.
 func NewCircuitBreaker(cb *gobreaker.CircuitBreaker) endpoint.Middleware {
 	return func(next endpoint.Endpoint) endpoint.Endpoint {
 		return func(ctx context.Context, request interface{}) (interface{}, error) {
-			res, err := cb.Execute(func() (interface{}, error) {
-				res, err := next(ctx, request)
-				if kitty.IsRetryable(err) {
-					return res, err
-				}
-				return cbResponse{res: res, err: err}, nil
-			})
-			if err == gobreaker.ErrOpenState || err == gobreaker.ErrTooManyRequests {
-				return nil, kitty.Retryable(err)
-			}
-			if cbres, ok := res.(cbResponse); ok {
-				return cbres.res, cbres.err
-			}
+			return execute(ctx, cb, next, request)
+		}
+	}
+}
+
+// execute calls the endpoint through the circuit breaker.
+func execute(ctx context.Context, cb *gobreaker.CircuitBreaker, next endpoint.Endpoint, request interface{}) (interface{}, error) {
+	res, err := cb.Execute(func() (interface{}, error) {
+		res, err := next(ctx, request)
+		if kitty.IsRetryable(err) {
 			return res, err
 		}
+		return cbResponse{res: res, err: err}, nil
+	})
+	if err == gobreaker.ErrOpenState || err == gobreaker.ErrTooManyRequests {
+		return nil, kitty.Retryable(err)
+	}
+	if cbres, ok := res.(cbResponse); ok {
+		return cbres.res, cbres.err
 	}
+	return res, err
 }
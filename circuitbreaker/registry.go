@@ -0,0 +1,163 @@
+package circuitbreaker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/objenious/kitty"
+	"github.com/sony/gobreaker"
+)
+
+// KeyFunc returns the key of a call (e.g. a host or a tenant id). A circuit breaker is used per key.
+type KeyFunc func(ctx context.Context, request interface{}) string
+
+// stateChange is a state change of a circuit breaker, not logged yet.
+type stateChange struct {
+	from, to gobreaker.State
+}
+
+// breaker is a circuit breaker of a registry.
+type breaker struct {
+	cb       *gobreaker.CircuitBreaker
+	lastUsed time.Time
+
+	mu      sync.Mutex
+	changes []stateChange
+}
+
+// Registry creates circuit breakers lazily, per key, so that a failing host or tenant does not open
+// the circuit breaker for all calls.
+// State changes are logged with the kitty logger of the call (see kitty.Logger).
+type Registry struct {
+	settings    gobreaker.Settings
+	key         KeyFunc
+	idleTimeout time.Duration
+
+	mu        sync.Mutex
+	breakers  map[string]*breaker
+	lastSweep time.Time
+}
+
+// NewRegistry creates a circuit breaker registry. Circuit breakers are created with settings,
+// named after their key, and are removed after being idle for 10 minutes.
+func NewRegistry(settings gobreaker.Settings, key KeyFunc) *Registry {
+	return &Registry{
+		settings:    settings,
+		key:         key,
+		idleTimeout: 10 * time.Minute,
+		breakers:    map[string]*breaker{},
+		lastSweep:   time.Now(),
+	}
+}
+
+// IdleTimeout defines after how long an unused circuit breaker is removed (default: 10 minutes).
+func (r *Registry) IdleTimeout(d time.Duration) *Registry {
+	r.idleTimeout = d
+	return r
+}
+
+// Middleware creates a circuit breaker middleware, using the circuit breaker of the key of each call.
+// Circuit breakers will only trigger on retryable errors (see kitty.IsRetryable).
+func (r *Registry) Middleware() endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			b := r.get(r.key(ctx, request))
+			res, err := execute(ctx, b.cb, next, request)
+			b.logChanges(ctx)
+			return res, err
+		}
+	}
+}
+
+// get returns the circuit breaker of a key, creating it if needed, and removes idle circuit breakers.
+func (r *Registry) get(key string) *breaker {
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if now.Sub(r.lastSweep) > r.idleTimeout {
+		for k, b := range r.breakers {
+			if now.Sub(b.lastUsed) > r.idleTimeout {
+				delete(r.breakers, k)
+			}
+		}
+		r.lastSweep = now
+	}
+	b, ok := r.breakers[key]
+	if !ok {
+		b = &breaker{}
+		settings := r.settings
+		settings.Name = key
+		settings.OnStateChange = func(name string, from, to gobreaker.State) {
+			b.mu.Lock()
+			b.changes = append(b.changes, stateChange{from: from, to: to})
+			b.mu.Unlock()
+			if r.settings.OnStateChange != nil {
+				r.settings.OnStateChange(name, from, to)
+			}
+		}
+		b.cb = gobreaker.NewCircuitBreaker(settings)
+		r.breakers[key] = b
+	}
+	b.lastUsed = now
+	return b
+}
+
+// logChanges logs the state changes of the circuit breaker.
+func (b *breaker) logChanges(ctx context.Context) {
+	b.mu.Lock()
+	changes := b.changes
+	b.changes = nil
+	b.mu.Unlock()
+	for _, c := range changes {
+		_ = kitty.LogMessage(ctx, "circuit breaker state changed", "circuit-breaker", b.cb.Name(), "from", c.from.String(), "to", c.to.String())
+	}
+}
+
+// States returns the current state ("closed", "half-open" or "open") of each circuit breaker, by key.
+func (r *Registry) States() map[string]string {
+	r.mu.Lock()
+	breakers := make([]*breaker, 0, len(r.breakers))
+	for _, b := range r.breakers {
+		breakers = append(breakers, b)
+	}
+	r.mu.Unlock()
+	states := make(map[string]string, len(breakers))
+	for _, b := range breakers {
+		states[b.cb.Name()] = b.cb.State().String()
+	}
+	return states
+}
+
+// Handler returns a handler writing the state of each circuit breaker as JSON.
+// It can be used as an admin handler (see kitty.HTTPTransport.AdminHandler).
+func (r *Registry) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(r.States())
+	}
+}
+
+// Check implements kitty.Checker, and returns an error if a circuit breaker is open.
+// It can be registered as a non critical health check, so that states are reported by health handlers.
+func (r *Registry) Check(context.Context) error {
+	var open []string
+	for key, state := range r.States() {
+		if state == gobreaker.StateOpen.String() {
+			open = append(open, key)
+		}
+	}
+	if len(open) == 0 {
+		return nil
+	}
+	sort.Strings(open)
+	return errors.New("open circuit breakers: " + strings.Join(open, ", "))
+}
+
+var _ kitty.Checker = &Registry{}
@@ -0,0 +1,50 @@
+package circuitbreaker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/objenious/kitty"
+	"github.com/sony/gobreaker"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry(gobreaker.Settings{ReadyToTrip: func(c gobreaker.Counts) bool { return c.ConsecutiveFailures >= 1 }},
+		func(_ context.Context, request interface{}) string { return request.(string) })
+	var e endpoint.Endpoint = func(_ context.Context, request interface{}) (interface{}, error) {
+		if request == "bad" {
+			return nil, &retryableError{}
+		}
+		return "OK", nil
+	}
+	e = r.Middleware()(e)
+	_, _ = e(context.TODO(), "bad")
+	if _, err := e(context.TODO(), "bad"); err == nil || err.Error() != gobreaker.ErrOpenState.Error() || !kitty.IsRetryable(err) {
+		t.Errorf("the circuit breaker of the failing key should be open, got %v", err)
+	}
+	if res, err := e(context.TODO(), "good"); err != nil || res != "OK" {
+		t.Errorf("the circuit breaker of other keys should be closed, got %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	r.Handler()(rec, httptest.NewRequest("GET", "/circuitbreakers", nil))
+	states := map[string]string{}
+	_ = json.NewDecoder(rec.Body).Decode(&states)
+	if states["bad"] != "open" || states["good"] != "closed" {
+		t.Errorf("invalid states %v", states)
+	}
+	if err := r.Check(context.TODO()); err == nil || err.Error() != "open circuit breakers: bad" {
+		t.Errorf("Check should report open circuit breakers, got %v", err)
+	}
+
+	r.IdleTimeout(10 * time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	_, _ = e(context.TODO(), "good")
+	if states := r.States(); len(states) != 1 {
+		t.Errorf("idle circuit breakers should be removed, got %v", states)
+	}
+}
@@ -2,9 +2,13 @@ package kitty
 
 import (
 	"context"
+	"errors"
+	"io"
+	"net"
 	"net/http"
 	"net/url"
 
+	"github.com/go-kit/kit/endpoint"
 	kithttp "github.com/go-kit/kit/transport/http"
 )
 
@@ -12,9 +16,88 @@ import (
 // It maps HTTP errors to Go errors.
 // As the mapped error implements StatusCode, the returned status code will also be used
 // as the status code returned by a go-kit HTTP endpoint.
-// When using the backoff middleware, only 429 & 5XX errors trigger a retry.
+// When using the backoff middleware, only retryable errors trigger a retry: 429 & 5XX errors,
+// and network errors (see DefaultClassifier), unless a specific classifier is defined (see Client.Classifier).
+// The id of the current request (see RequestIDFromContext), the deadline of the context (see DeadlineHeader)
+// and its idempotency key (see WithIdempotencyKey) are forwarded to the called service.
 type Client struct {
 	*kithttp.Client
+	classifier  Classifier
+	statusCodes map[int]bool
+	bodyLimit   int
+}
+
+// Classifier decides if an error returned by a client is retryable.
+type Classifier func(err error) bool
+
+// DefaultClassifier is the default client classifier.
+// Retryable errors (see IsRetryable), network errors (connection refused, DNS failures, timeouts, reset connections...),
+// unexpected EOFs and deadline exceeded errors are retryable. Canceled requests are not retryable.
+func DefaultClassifier(err error) bool {
+	if IsRetryable(err) {
+		return true
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		return false
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		return true
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr)
+}
+
+// Classifier defines the function deciding if an error is retryable (default: DefaultClassifier).
+func (c *Client) Classifier(fn Classifier) *Client {
+	c.classifier = fn
+	return c
+}
+
+// RetryableStatusCodes defines the list of retryable HTTP status codes (default: 429, 500, 502, 503 & 504).
+// It overrides the classifier for errors implementing kithttp.StatusCoder.
+func (c *Client) RetryableStatusCodes(codes ...int) *Client {
+	c.statusCodes = make(map[int]bool, len(codes))
+	for _, code := range codes {
+		c.statusCodes[code] = true
+	}
+	return c
+}
+
+// ErrorBodyLimit defines the maximum number of bytes of the response body kept in errors
+// (see HTTPErrorWithLimit, default: DefaultErrorBodyLimit).
+// It is not used by clients created with NewClientWithError.
+func (c *Client) ErrorBodyLimit(n int) *Client {
+	c.bodyLimit = n
+	return c
+}
+
+// Endpoint returns a usable endpoint that invokes the remote endpoint.
+// Returned errors are classified as retryable or not.
+func (c *Client) Endpoint() endpoint.Endpoint {
+	e := c.Client.Endpoint()
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		response, err := e(ctx, request)
+		if err != nil {
+			err = c.classify(err)
+		}
+		return response, err
+	}
+}
+
+// classify marks err as retryable or not, if its classification differs from IsRetryable.
+func (c *Client) classify(err error) error {
+	retryable := c.classifier(err)
+	if code, ok := LookupStatusCode(err); ok && c.statusCodes != nil {
+		retryable = c.statusCodes[code]
+	}
+	switch {
+	case retryable == IsRetryable(err):
+		return err
+	case retryable:
+		return Retryable(err)
+	default:
+		return Permanent(err)
+	}
 }
 
 // NewClient creates a kitty client.
@@ -25,7 +108,9 @@ func NewClient(
 	dec kithttp.DecodeResponseFunc,
 	options ...kithttp.ClientOption,
 ) *Client {
-	return &Client{Client: kithttp.NewClient(method, tgt, enc, makeDecodeResponseFunc(dec), options...)}
+	c := newClient()
+	c.Client = kithttp.NewClient(method, tgt, enc, c.makeDecodeResponseFunc(dec), clientOptions(options)...)
+	return c
 }
 
 // NewClientWithError creates a kitty client that doesn't deal with HTTP errors.
@@ -37,13 +122,25 @@ func NewClientWithError(
 	dec kithttp.DecodeResponseFunc,
 	options ...kithttp.ClientOption,
 ) *Client {
-	return &Client{Client: kithttp.NewClient(method, tgt, enc, dec, options...)}
+	c := newClient()
+	c.Client = kithttp.NewClient(method, tgt, enc, dec, clientOptions(options)...)
+	return c
+}
+
+// newClient creates a kitty client with the default configuration.
+func newClient() *Client {
+	return &Client{classifier: DefaultClassifier, bodyLimit: DefaultErrorBodyLimit}
+}
+
+// clientOptions adds the default client options to options.
+func clientOptions(options []kithttp.ClientOption) []kithttp.ClientOption {
+	return append([]kithttp.ClientOption{kithttp.ClientBefore(forwardRequestID, forwardDeadline, forwardIdempotencyKey)}, options...)
 }
 
 // makeDecodeResponseFunc maps HTTP errors to Go errors.
-func makeDecodeResponseFunc(fn kithttp.DecodeResponseFunc) kithttp.DecodeResponseFunc {
+func (c *Client) makeDecodeResponseFunc(fn kithttp.DecodeResponseFunc) kithttp.DecodeResponseFunc {
 	return func(ctx context.Context, resp *http.Response) (interface{}, error) {
-		if err := HTTPError(resp); err != nil {
+		if err := HTTPErrorWithLimit(resp, c.bodyLimit); err != nil {
 			return nil, err
 		}
 		return fn(ctx, resp)
@@ -3,6 +3,9 @@ package kitty
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -97,6 +100,82 @@ func TestClientWithError(t *testing.T) {
 	}
 }
 
+func TestClientClassifier(t *testing.T) {
+	h := testHandler{}
+	ts := httptest.NewServer(&h)
+	u, _ := url.Parse(ts.URL)
+	c := NewClient("GET", u, kithttp.EncodeJSONRequest, decodeTestResponse)
+	e := c.Endpoint()
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+	if _, err := e(ctx, nil); err == nil || IsRetryable(err) {
+		t.Errorf("A canceled request should not be retryable, got %v", err)
+	}
+
+	e = NewClient("GET", u, kithttp.EncodeJSONRequest, decodeTestResponse).
+		RetryableStatusCodes(http.StatusRequestTimeout, http.StatusConflict).Endpoint()
+	h.statuses = []int{http.StatusConflict}
+	if _, err := e(context.TODO(), nil); !IsRetryable(err) {
+		t.Errorf("A 409 error should be retryable, got %v", err)
+	}
+	h.statuses = []int{http.StatusServiceUnavailable}
+	if _, err := e(context.TODO(), nil); err == nil || IsRetryable(err) {
+		t.Errorf("A 503 error should not be retryable, got %v", err)
+	}
+
+	ts.Close()
+	if _, err := e(context.TODO(), nil); !IsRetryable(err) {
+		t.Errorf("A connection error should be retryable, got %v", err)
+	}
+	e = NewClient("GET", u, kithttp.EncodeJSONRequest, decodeTestResponse).
+		Classifier(func(error) bool { return false }).Endpoint()
+	if _, err := e(context.TODO(), nil); err == nil || IsRetryable(err) {
+		t.Errorf("The classifier should define if an error is retryable, got %v", err)
+	}
+
+	if DefaultClassifier(fmt.Errorf("foo: %w", context.Canceled)) {
+		t.Error("A wrapped canceled error should not be retryable")
+	}
+	if !DefaultClassifier(fmt.Errorf("foo: %w", &net.OpError{Op: "dial", Err: errors.New("refused")})) {
+		t.Error("A wrapped network error should be retryable")
+	}
+}
+
+func TestClientErrorBody(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// go-kit client options should still be applied
+		if r.Header.Get("X-Bar") != "baz" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("X-Foo", "bar")
+		w.WriteHeader(http.StatusConflict)
+		_, _ = w.Write([]byte("invalid name"))
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL)
+	e := NewClient("GET", u, kithttp.EncodeJSONRequest, decodeTestResponse,
+		kithttp.ClientBefore(func(ctx context.Context, r *http.Request) context.Context {
+			r.Header.Set("X-Bar", "baz")
+			return ctx
+		})).
+		RetryableStatusCodes(http.StatusConflict).
+		ErrorBodyLimit(7).
+		Endpoint()
+	_, err := e(context.TODO(), nil)
+	var rerr *ResponseError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("the client should return a *ResponseError, got %T", err)
+	}
+	if rerr.StatusCode() != http.StatusConflict || string(rerr.Body) != "invalid" || rerr.Header.Get("X-Foo") != "bar" {
+		t.Errorf("invalid error %+v", rerr)
+	}
+	if !IsRetryable(err) {
+		t.Error("the error should be retryable")
+	}
+}
+
 var testData = testStruct{Foo: "bar"}
 
 type testHandler struct {
@@ -0,0 +1,90 @@
+package concurrency
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Sample is the outcome of a call, used to adjust the concurrency limit.
+type Sample struct {
+	// Latency is the duration of the call.
+	Latency time.Duration
+	// InFlight is the number of calls in flight when the call started (including the call).
+	InFlight int
+	// Dropped is true if the call failed because of overload, as decided by the classifier of the limiter
+	// (see Limiter.Classifier; by default, timeouts and errors marked with Overloaded).
+	Dropped bool
+}
+
+// Algorithm computes the concurrency limit.
+type Algorithm interface {
+	// Update returns the new limit, given the current limit and the sample of a call.
+	Update(limit int, s Sample) int
+}
+
+// aimd is an additive increase, multiplicative decrease algorithm.
+type aimd struct {
+	timeout time.Duration
+	backoff float64
+}
+
+// AIMD creates an additive increase, multiplicative decrease algorithm:
+// the limit is increased by 1 after each successful call, and decreased by 10% after a dropped call,
+// or a call slower than timeout.
+func AIMD(timeout time.Duration) Algorithm {
+	return &aimd{timeout: timeout, backoff: 0.9}
+}
+
+// Update implements Algorithm.
+func (a *aimd) Update(limit int, s Sample) int {
+	if s.Dropped || s.Latency > a.timeout {
+		return int(float64(limit) * a.backoff)
+	}
+	// the limit is only increased if it is used, otherwise it would grow indefinitely
+	if s.InFlight*2 >= limit {
+		return limit + 1
+	}
+	return limit
+}
+
+// gradient is a gradient algorithm, comparing the latency of calls to the long term latency.
+type gradient struct {
+	mu       sync.Mutex
+	estimate float64
+	longRTT  float64
+}
+
+// Gradient creates a gradient algorithm (based on Netflix's Gradient2): the limit is adjusted based on the ratio
+// between the long term average latency and the latency of each call, so that the limit decreases when
+// requests get queued (and latency increases), and increases otherwise.
+func Gradient() Algorithm {
+	return &gradient{}
+}
+
+// Update implements Algorithm.
+func (g *gradient) Update(limit int, s Sample) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	// the limit may have been bounded by the limiter
+	if int(g.estimate) != limit {
+		g.estimate = float64(limit)
+	}
+	rtt := float64(s.Latency)
+	if g.longRTT == 0 {
+		g.longRTT = rtt
+	}
+	g.longRTT = g.longRTT*0.99 + rtt*0.01
+	// the limit is not increased if it is not used
+	if !s.Dropped && float64(s.InFlight)*2 < g.estimate {
+		return int(g.estimate)
+	}
+	grad := 0.5
+	if !s.Dropped && rtt > 0 {
+		grad = math.Max(0.5, math.Min(1, g.longRTT/rtt))
+	}
+	// the square root of the limit is the allowed queue size
+	target := g.estimate*grad + math.Sqrt(g.estimate)
+	g.estimate = g.estimate*0.8 + target*0.2
+	return int(g.estimate)
+}
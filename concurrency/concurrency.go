@@ -0,0 +1,203 @@
+package concurrency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/objenious/kitty"
+)
+
+// Priority is the priority of an endpoint. Calls to lower priority endpoints are shed first.
+type Priority int
+
+const (
+	// Sheddable calls can use half of the concurrency limit.
+	Sheddable Priority = iota
+	// Default calls can use 90% of the concurrency limit.
+	Default
+	// Critical calls can use the whole concurrency limit.
+	Critical
+)
+
+// String returns the name of the priority.
+func (p Priority) String() string {
+	switch p {
+	case Sheddable:
+		return "sheddable"
+	case Critical:
+		return "critical"
+	default:
+		return "default"
+	}
+}
+
+// capacity returns the number of calls in flight allowed for a priority.
+func (p Priority) capacity(limit int) int {
+	switch p {
+	case Sheddable:
+		return int(math.Ceil(float64(limit) * 0.5))
+	case Critical:
+		return limit
+	default:
+		return int(math.Ceil(float64(limit) * 0.9))
+	}
+}
+
+// ErrLimitExceeded is the cause of the errors returned to shed calls.
+var ErrLimitExceeded = errors.New("concurrency limit exceeded")
+
+// Classifier decides if a call failed because the service is overloaded.
+type Classifier func(ctx context.Context, err error) bool
+
+// DefaultClassifier is the default limiter classifier: timeouts (deadline exceeded errors, see kitty.TimeoutEndpoint)
+// and overload errors (see Overloaded) are caused by overload. Other errors (e.g. 429 errors of a rate limiter,
+// or 5XX errors of dependencies) are not, and do not decrease the limit.
+func DefaultClassifier(ctx context.Context, err error) bool {
+	var oerr *overloadError
+	return ctx.Err() == context.DeadlineExceeded || errors.Is(err, context.DeadlineExceeded) || errors.As(err, &oerr)
+}
+
+// overloadError is an error caused by overload.
+type overloadError struct {
+	error
+}
+
+// Unwrap returns the wrapped error (see errors.As).
+func (e *overloadError) Unwrap() error {
+	return e.error
+}
+
+// Overloaded marks an error as caused by the overload of the service (e.g. an exhausted connection pool),
+// so that it decreases the limit (see DefaultClassifier). The returned error is a retryable 503 error.
+func Overloaded(err error) error {
+	return kitty.Unavailable(&overloadError{error: err})
+}
+
+// Stats holds the state of a limiter.
+type Stats struct {
+	Limit    int `json:"limit"`
+	InFlight int `json:"in_flight"`
+	// Rejected holds the number of rejected calls, by priority.
+	Rejected map[string]uint64 `json:"rejected"`
+}
+
+// Limiter limits the number of calls in flight. The limit is adjusted by an algorithm (e.g. AIMD or Gradient),
+// based on the observed latency, so that calls are shed instead of being queued when a service is overloaded.
+// A limiter should be shared by all endpoints of a service.
+type Limiter struct {
+	alg        Algorithm
+	classifier Classifier
+	min, max   int
+
+	mu       sync.Mutex
+	limit    int
+	inflight int
+	rejected map[Priority]uint64
+}
+
+// NewLimiter creates a concurrency limiter, with an initial limit of 20 calls, between 1 and 1000 calls.
+func NewLimiter(alg Algorithm) *Limiter {
+	return &Limiter{alg: alg, classifier: DefaultClassifier, min: 1, max: 1000, limit: 20, rejected: map[Priority]uint64{}}
+}
+
+// Classifier defines the function deciding if a call failed because of overload (default: DefaultClassifier).
+func (l *Limiter) Classifier(fn Classifier) *Limiter {
+	l.classifier = fn
+	return l
+}
+
+// InitialLimit defines the initial limit (default: 20).
+func (l *Limiter) InitialLimit(n int) *Limiter {
+	l.limit = n
+	return l
+}
+
+// Bounds defines the minimum and maximum limits (default: 1 and 1000).
+func (l *Limiter) Bounds(min, max int) *Limiter {
+	l.min, l.max = min, max
+	return l
+}
+
+// Middleware creates a concurrency limiting middleware, for endpoints of priority p.
+// Calls exceeding the limit of their priority get a retryable 503 error.
+// Calls failing because of overload (see Classifier) decrease the limit.
+func (l *Limiter) Middleware(p Priority) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			inflight, ok := l.acquire(p)
+			if !ok {
+				return nil, kitty.Unavailable(ErrLimitExceeded)
+			}
+			// the slot is released even if the endpoint panics
+			var sample *Sample
+			defer func() { l.release(sample) }()
+			start := time.Now()
+			res, err := next(ctx, request)
+			// calls canceled by the client do not say anything about the load
+			if ctx.Err() != context.Canceled {
+				sample = &Sample{
+					Latency:  time.Since(start),
+					InFlight: inflight,
+					Dropped:  err != nil && l.classifier(ctx, err),
+				}
+			}
+			return res, err
+		}
+	}
+}
+
+// acquire reserves a slot for a call of priority p, and returns the number of calls in flight.
+func (l *Limiter) acquire(p Priority) (int, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inflight >= p.capacity(l.limit) {
+		l.rejected[p]++
+		return 0, false
+	}
+	l.inflight++
+	return l.inflight, true
+}
+
+// release releases the slot of a call, and updates the limit.
+func (l *Limiter) release(s *Sample) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inflight--
+	if s == nil {
+		return
+	}
+	limit := l.alg.Update(l.limit, *s)
+	if limit < l.min {
+		limit = l.min
+	}
+	if limit > l.max {
+		limit = l.max
+	}
+	l.limit = limit
+}
+
+// Stats returns the current limit, the number of calls in flight and the number of rejected calls.
+func (l *Limiter) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := Stats{Limit: l.limit, InFlight: l.inflight, Rejected: make(map[string]uint64, len(l.rejected))}
+	for p, n := range l.rejected {
+		s.Rejected[p.String()] = n
+	}
+	return s
+}
+
+// Handler returns a handler writing the stats of the limiter as JSON.
+// It can be used as an admin handler (see kitty.HTTPTransport.AdminHandler).
+func (l *Limiter) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(l.Stats())
+	}
+}
@@ -0,0 +1,166 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/objenious/kitty"
+)
+
+// fixed is an algorithm that never changes the limit.
+type fixed struct{}
+
+func (fixed) Update(limit int, _ Sample) int { return limit }
+
+func TestPriorities(t *testing.T) {
+	l := NewLimiter(fixed{}).InitialLimit(10)
+	release := make(chan struct{})
+	started := make(chan struct{})
+	blocking := func(context.Context, interface{}) (interface{}, error) {
+		started <- struct{}{}
+		<-release
+		return nil, nil
+	}
+	nop := func(context.Context, interface{}) (interface{}, error) { return nil, nil }
+
+	// 5 calls in flight: sheddable calls are rejected
+	for i := 0; i < 5; i++ {
+		go func() { _, _ = l.Middleware(Critical)(blocking)(context.TODO(), nil) }()
+		<-started
+	}
+	_, err := l.Middleware(Sheddable)(nop)(context.TODO(), nil)
+	if !kitty.IsRetryable(err) || kitty.ErrorStatusCode(err) != http.StatusServiceUnavailable || !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("sheddable call should get a retryable 503 error, got %v", err)
+	}
+	if _, err := l.Middleware(Default)(nop)(context.TODO(), nil); err != nil {
+		t.Errorf("default call should be allowed, got %v", err)
+	}
+
+	// 9 calls in flight: default calls are rejected
+	for i := 0; i < 4; i++ {
+		go func() { _, _ = l.Middleware(Critical)(blocking)(context.TODO(), nil) }()
+		<-started
+	}
+	if _, err := l.Middleware(Default)(nop)(context.TODO(), nil); err == nil {
+		t.Errorf("default call should be rejected")
+	}
+	if _, err := l.Middleware(Critical)(nop)(context.TODO(), nil); err != nil {
+		t.Errorf("critical call should be allowed, got %v", err)
+	}
+	close(release)
+
+	stats := l.Stats()
+	if stats.Rejected["sheddable"] != 1 || stats.Rejected["default"] != 1 || stats.Rejected["critical"] != 0 {
+		t.Errorf("invalid rejection counts: %v", stats.Rejected)
+	}
+
+	w := httptest.NewRecorder()
+	l.Handler()(w, httptest.NewRequest("GET", "/concurrency", nil))
+	if !strings.Contains(w.Body.String(), `"rejected":{"default":1,"sheddable":1}`) {
+		t.Errorf("invalid stats: %s", w.Body.String())
+	}
+}
+
+func TestAIMD(t *testing.T) {
+	tcs := []struct {
+		sample   Sample
+		limit    int
+		expected int
+	}{
+		{sample: Sample{InFlight: 5}, limit: 10, expected: 11},
+		{sample: Sample{InFlight: 4}, limit: 10, expected: 10},
+		{sample: Sample{InFlight: 10, Dropped: true}, limit: 10, expected: 9},
+		{sample: Sample{InFlight: 10, Latency: 20 * time.Millisecond}, limit: 10, expected: 9},
+	}
+	for _, tc := range tcs {
+		if limit := AIMD(10*time.Millisecond).Update(tc.limit, tc.sample); limit != tc.expected {
+			t.Errorf("%+v: expected %d, got %d", tc.sample, tc.expected, limit)
+		}
+	}
+}
+
+func TestBounds(t *testing.T) {
+	l := NewLimiter(AIMD(10*time.Millisecond)).InitialLimit(6).Bounds(5, 11)
+	failing := func(context.Context, interface{}) (interface{}, error) {
+		return nil, Overloaded(errors.New("overloaded"))
+	}
+	for i := 0; i < 10; i++ {
+		_, _ = l.Middleware(Critical)(failing)(context.TODO(), nil)
+	}
+	if limit := l.Stats().Limit; limit != 5 {
+		t.Errorf("limit should be bounded, got %d", limit)
+	}
+}
+
+func TestGradient(t *testing.T) {
+	g := Gradient()
+	limit := 20
+	for i := 0; i < 50; i++ {
+		limit = g.Update(limit, Sample{Latency: 10 * time.Millisecond, InFlight: limit})
+	}
+	if limit <= 20 {
+		t.Errorf("limit should increase with a stable latency, got %d", limit)
+	}
+	increased := limit
+	for i := 0; i < 20; i++ {
+		limit = g.Update(limit, Sample{Latency: 100 * time.Millisecond, InFlight: limit})
+	}
+	if limit >= increased {
+		t.Errorf("limit should decrease when latency increases, got %d", limit)
+	}
+	if l := g.Update(limit, Sample{Latency: 10 * time.Millisecond, InFlight: 1}); l != limit {
+		t.Errorf("unused limit should not change, got %d", l)
+	}
+	if l := g.Update(limit, Sample{Latency: 10 * time.Millisecond, InFlight: limit, Dropped: true}); l >= limit {
+		t.Errorf("limit should decrease after a dropped call, got %d", l)
+	}
+}
+
+func TestPanic(t *testing.T) {
+	l := NewLimiter(fixed{}).InitialLimit(1)
+	e := l.Middleware(Critical)(func(context.Context, interface{}) (interface{}, error) { panic("failure") })
+	func() {
+		defer func() { _ = recover() }()
+		_, _ = e(context.TODO(), nil)
+	}()
+	if n := l.Stats().InFlight; n != 0 {
+		t.Errorf("the slot should be released when the endpoint panics, got %d calls in flight", n)
+	}
+}
+
+func TestClassifier(t *testing.T) {
+	deadline, cancel := context.WithTimeout(context.TODO(), -time.Second)
+	defer cancel()
+	tcs := []struct {
+		ctx     context.Context
+		err     error
+		dropped bool
+	}{
+		{ctx: context.TODO(), err: kitty.TooManyRequests(errors.New("rate limited")), dropped: false},
+		{ctx: context.TODO(), err: kitty.Unavailable(errors.New("dependency unavailable")), dropped: false},
+		{ctx: context.TODO(), err: Overloaded(errors.New("pool exhausted")), dropped: true},
+		{ctx: context.TODO(), err: kitty.WithStatus(context.DeadlineExceeded, http.StatusGatewayTimeout), dropped: true},
+		{ctx: deadline, err: errors.New("failure"), dropped: true},
+	}
+	for _, tc := range tcs {
+		if dropped := DefaultClassifier(tc.ctx, tc.err); dropped != tc.dropped {
+			t.Errorf("DefaultClassifier(%v) should return %t", tc.err, tc.dropped)
+		}
+	}
+	if err := Overloaded(errors.New("pool exhausted")); !kitty.IsRetryable(err) || kitty.ErrorStatusCode(err) != http.StatusServiceUnavailable {
+		t.Errorf("overload errors should be retryable 503 errors, got %v", err)
+	}
+
+	l := NewLimiter(AIMD(time.Second)).InitialLimit(10).Classifier(func(context.Context, error) bool { return true })
+	_, _ = l.Middleware(Critical)(func(context.Context, interface{}) (interface{}, error) {
+		return nil, errors.New("failure")
+	})(context.TODO(), nil)
+	if limit := l.Stats().Limit; limit != 9 {
+		t.Errorf("the classifier should define dropped calls, got a limit of %d", limit)
+	}
+}
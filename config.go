@@ -1,6 +1,10 @@
 package kitty
 
-import kithttp "github.com/go-kit/kit/transport/http"
+import (
+	"time"
+
+	kithttp "github.com/go-kit/kit/transport/http"
+)
 
 // Config holds configuration info for kitty.HTTPTransport.
 type Config struct {
@@ -12,8 +16,29 @@ type Config struct {
 	HTTPPort int
 	// EnablePProf enables pprof urls (default: false).
 	EnablePProf bool
+	// AdminHTTPPort is the port of the admin server, serving health checks, pprof urls and admin handlers.
+	// If not set, they are served on HTTPPort (default: 0).
+	AdminHTTPPort int
+	// AdminHTTPAddress is the address (e.g. "127.0.0.1") the admin server will listen on (default: all interfaces).
+	AdminHTTPAddress string
+	// TLSCertFile is the path of the PEM encoded TLS certificate. If set, the server will use HTTPS.
+	// The certificate is loaded again when the certificate or key files are modified.
+	TLSCertFile string
+	// TLSKeyFile is the path of the PEM encoded TLS private key.
+	TLSKeyFile string
+	// TLSClientCAFile is the path of the PEM encoded CA certificates used to verify client certificates.
+	// If set, clients must present a valid certificate (mutual TLS).
+	TLSClientCAFile string
 	// EncodeResponse defines the default response encoder for all endpoints (by default: EncodeJSONResponse). It can be overriden for a specific endpoint.
 	EncodeResponse kithttp.EncodeResponseFunc
+	// EncodeError defines the default error encoder for all endpoints (by default: EncodeError).
+	// EncodeProblem writes errors as RFC 7807 problem details. It can be overriden for a specific endpoint.
+	EncodeError kithttp.ErrorEncoder
+	// MinRequestTimeout is the minimum remaining time of requests sent with a deadline by kitty clients
+	// (see DeadlineHeader, default: 0). Requests with less remaining time are rejected with a retryable 504 error.
+	MinRequestTimeout time.Duration
+	// MaxRequestTimeout caps the timeout applied to requests sent with a deadline by kitty clients (default: no cap).
+	MaxRequestTimeout time.Duration
 }
 
 // DefaultConfig defines the default config of kitty.HTTPTransport.
@@ -23,4 +48,5 @@ var DefaultConfig = Config{
 	ReadinessCheckPath: "/readyz",
 	EnablePProf:        false,
 	EncodeResponse:     kithttp.EncodeJSONResponse,
+	EncodeError:        EncodeError,
 }
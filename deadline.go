@@ -0,0 +1,56 @@
+package kitty
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DeadlineHeader is the header used to propagate the remaining time of a request (in milliseconds) to called services.
+const DeadlineHeader = "X-Request-Timeout"
+
+// errDeadlineExceeded is a retryable 504 error, returned when a request or an endpoint exceeds its deadline.
+var errDeadlineExceeded = WithStatus(context.DeadlineExceeded, http.StatusGatewayTimeout)
+
+// serveWithDeadline applies the deadline of the caller (see DeadlineHeader) to the request context,
+// capped by MaxRequestTimeout.
+// Requests that are already past their deadline, or with less remaining time than MinRequestTimeout,
+// are rejected with a retryable 504 error, as the caller would give up before they are processed.
+func (t *HTTPTransport) serveWithDeadline(w http.ResponseWriter, r *http.Request) {
+	v := r.Header.Get(DeadlineHeader)
+	if v == "" {
+		t.mux.ServeHTTP(w, r)
+		return
+	}
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		t.mux.ServeHTTP(w, r)
+		return
+	}
+	timeout := time.Duration(ms) * time.Millisecond
+	if ms <= 0 || timeout < t.cfg.MinRequestTimeout {
+		t.cfg.EncodeError(r.Context(), errDeadlineExceeded, w)
+		return
+	}
+	if t.cfg.MaxRequestTimeout > 0 && timeout > t.cfg.MaxRequestTimeout {
+		timeout = t.cfg.MaxRequestTimeout
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	t.mux.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// forwardDeadline sets the remaining time of the context to an outgoing request.
+func forwardDeadline(ctx context.Context, r *http.Request) context.Context {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return ctx
+	}
+	ms := time.Until(deadline).Milliseconds()
+	if ms < 0 {
+		ms = 0
+	}
+	r.Header.Set(DeadlineHeader, strconv.FormatInt(ms, 10))
+	return ctx
+}
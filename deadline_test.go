@@ -0,0 +1,73 @@
+package kitty
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	kithttp "github.com/go-kit/kit/transport/http"
+)
+
+func TestDeadline(t *testing.T) {
+	var remaining time.Duration
+	var hasDeadline bool
+	tr := NewHTTPTransport(Config{MaxRequestTimeout: time.Second, MinRequestTimeout: 100 * time.Millisecond}).
+		Endpoint("GET", "/foo", func(ctx context.Context, _ interface{}) (interface{}, error) {
+			var deadline time.Time
+			deadline, hasDeadline = ctx.Deadline()
+			remaining = time.Until(deadline)
+			return nil, nil
+		})
+	_ = tr.RegisterEndpoints(nopMiddleware)
+	ts := httptest.NewServer(tr)
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL + "/foo")
+	e := NewClient("GET", u, kithttp.EncodeJSONRequest, func(context.Context, *http.Response) (interface{}, error) {
+		return nil, nil
+	}).Endpoint()
+
+	tcs := []struct {
+		timeout  time.Duration
+		min, max time.Duration
+	}{
+		{timeout: 500 * time.Millisecond, min: 300 * time.Millisecond, max: 500 * time.Millisecond},
+		{timeout: time.Minute, min: 800 * time.Millisecond, max: time.Second},
+	}
+	for _, tc := range tcs {
+		ctx, cancel := context.WithTimeout(context.TODO(), tc.timeout)
+		hasDeadline = false
+		if _, err := e(ctx, nil); err != nil {
+			t.Errorf("the call should succeed, got %v", err)
+		}
+		cancel()
+		if !hasDeadline || remaining < tc.min || remaining > tc.max {
+			t.Errorf("with a %s timeout, the remaining time should be between %s and %s, got %s", tc.timeout, tc.min, tc.max, remaining)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+	defer cancel()
+	hasDeadline = false
+	if _, err := e(ctx, nil); ErrorStatusCode(err) != http.StatusGatewayTimeout || !IsRetryable(err) || hasDeadline {
+		t.Errorf("a request with less remaining time than MinRequestTimeout should be rejected with a retryable 504 error, got %v", err)
+	}
+
+	hasDeadline = false
+	if _, err := e(context.TODO(), nil); err != nil || hasDeadline {
+		t.Errorf("without deadline, no deadline should be applied, got %v", err)
+	}
+
+	req, _ := http.NewRequest("GET", ts.URL+"/foo", nil)
+	req.Header.Set(DeadlineHeader, "0")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if err := HTTPError(resp); ErrorStatusCode(err) != http.StatusGatewayTimeout || !IsRetryable(err) {
+		t.Errorf("a request past its deadline should be rejected with a retryable 504 error, got %v", err)
+	}
+}
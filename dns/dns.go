@@ -0,0 +1,202 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/sd"
+)
+
+// Resolver resolves DNS records. It is implemented by *net.Resolver, and can be replaced by a fake DNS in tests.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// config holds the configuration of an instancer.
+type config struct {
+	resolver Resolver
+	interval time.Duration
+	timeout  time.Duration
+	scheme   string
+}
+
+// Option is an instancer option.
+type Option func(*config) *config
+
+// WithResolver defines the DNS resolver (default: net.DefaultResolver).
+func WithResolver(r Resolver) Option {
+	return func(c *config) *config {
+		c.resolver = r
+		return c
+	}
+}
+
+// RefreshInterval defines how often records are resolved (default: 30s).
+// It is a fixed interval: the TTL of records is not available with the Go resolver, and is ignored.
+func RefreshInterval(d time.Duration) Option {
+	return func(c *config) *config {
+		c.interval = d
+		return c
+	}
+}
+
+// LookupTimeout defines the maximum duration of a lookup (default: 5s).
+func LookupTimeout(d time.Duration) Option {
+	return func(c *config) *config {
+		c.timeout = d
+		return c
+	}
+}
+
+// Scheme defines the scheme of instance URLs (default: "http").
+func Scheme(scheme string) Option {
+	return func(c *config) *config {
+		c.scheme = scheme
+		return c
+	}
+}
+
+// Instancer is a go-kit sd.Instancer, periodically resolving DNS records into instance URLs
+// (e.g. "http://10.0.0.1:8080"), that can be used by a load balanced client (see the balancer package).
+// Instances removed from DNS are removed from the instance list.
+type Instancer struct {
+	cfg    *config
+	lookup func(ctx context.Context) ([]string, error)
+
+	mu        sync.Mutex
+	state     sd.Event
+	listeners map[chan<- sd.Event]bool
+	stop      chan struct{}
+	stopOnce  sync.Once
+}
+
+var _ sd.Instancer = &Instancer{}
+
+// NewHostInstancer creates an instancer resolving the A/AAAA records of host (e.g. a Kubernetes headless service),
+// with instances listening on port.
+func NewHostInstancer(host string, port int, opts ...Option) *Instancer {
+	i := newInstancer(opts)
+	i.lookup = func(ctx context.Context) ([]string, error) {
+		addrs, err := i.cfg.resolver.LookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		instances := make([]string, len(addrs))
+		for j, addr := range addrs {
+			instances[j] = i.cfg.scheme + "://" + net.JoinHostPort(addr, strconv.Itoa(port))
+		}
+		return instances, nil
+	}
+	i.start()
+	return i
+}
+
+// NewSRVInstancer creates an instancer resolving the SRV records of _service._proto.name
+// (e.g. NewSRVInstancer("http", "tcp", "foo.default.svc.cluster.local")).
+// If service and proto are empty, name is resolved directly.
+func NewSRVInstancer(service, proto, name string, opts ...Option) *Instancer {
+	i := newInstancer(opts)
+	i.lookup = func(ctx context.Context) ([]string, error) {
+		_, srvs, err := i.cfg.resolver.LookupSRV(ctx, service, proto, name)
+		if err != nil {
+			return nil, err
+		}
+		instances := make([]string, len(srvs))
+		for j, srv := range srvs {
+			instances[j] = fmt.Sprintf("%s://%s", i.cfg.scheme, net.JoinHostPort(strings.TrimSuffix(srv.Target, "."), strconv.Itoa(int(srv.Port))))
+		}
+		return instances, nil
+	}
+	i.start()
+	return i
+}
+
+func newInstancer(opts []Option) *Instancer {
+	cfg := &config{resolver: net.DefaultResolver, interval: 30 * time.Second, timeout: 5 * time.Second, scheme: "http"}
+	for _, opt := range opts {
+		cfg = opt(cfg)
+	}
+	return &Instancer{cfg: cfg, listeners: map[chan<- sd.Event]bool{}, stop: make(chan struct{})}
+}
+
+// start resolves records, then refreshes them every refresh interval.
+func (i *Instancer) start() {
+	i.resolve()
+	go func() {
+		ticker := time.NewTicker(i.cfg.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				i.resolve()
+			case <-i.stop:
+				return
+			}
+		}
+	}()
+}
+
+// resolve resolves records, and notifies listeners if instances changed.
+// If the name does not exist, there is no instance.
+func (i *Instancer) resolve() {
+	ctx, cancel := context.WithTimeout(context.Background(), i.cfg.timeout)
+	defer cancel()
+	instances, err := i.lookup(ctx)
+	if derr, ok := err.(*net.DNSError); ok && derr.IsNotFound {
+		instances, err = []string{}, nil
+	}
+	ev := sd.Event{Instances: instances, Err: err}
+	sort.Strings(ev.Instances)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if ev.Err == nil && i.state.Err == nil && i.state.Instances != nil && equal(ev.Instances, i.state.Instances) {
+		return
+	}
+	i.state = ev
+	for ch := range i.listeners {
+		ch <- ev
+	}
+}
+
+// equal checks if two sorted instance lists are equal.
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for j := range a {
+		if a[j] != b[j] {
+			return false
+		}
+	}
+	return true
+}
+
+// Register implements sd.Instancer. The current instances are sent immediately.
+func (i *Instancer) Register(ch chan<- sd.Event) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.listeners[ch] = true
+	ch <- i.state
+}
+
+// Deregister implements sd.Instancer.
+func (i *Instancer) Deregister(ch chan<- sd.Event) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	delete(i.listeners, ch)
+}
+
+// Stop implements sd.Instancer, and stops refreshing records.
+func (i *Instancer) Stop() {
+	i.stopOnce.Do(func() {
+		close(i.stop)
+	})
+}
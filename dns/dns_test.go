@@ -0,0 +1,91 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/objenious/kitty/balancer"
+)
+
+type fakeResolver struct {
+	mu    sync.Mutex
+	hosts []string
+	srvs  []*net.SRV
+}
+
+func (r *fakeResolver) set(hosts []string, srvs []*net.SRV) {
+	r.mu.Lock()
+	r.hosts, r.srvs = hosts, srvs
+	r.mu.Unlock()
+}
+
+func (r *fakeResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.hosts) == 0 {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return r.hosts, nil
+}
+
+func (r *fakeResolver) LookupSRV(_ context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return "", r.srvs, nil
+}
+
+func nopDecoder(context.Context, *http.Response) (interface{}, error) {
+	return nil, nil
+}
+
+func instanceURLs(c *balancer.Client) []string {
+	var urls []string
+	for _, inst := range c.Instances() {
+		urls = append(urls, inst.URL)
+	}
+	return urls
+}
+
+func waitFor(t *testing.T, c *balancer.Client, expected []string) {
+	t.Helper()
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(5 * time.Millisecond) {
+		if reflect.DeepEqual(instanceURLs(c), expected) {
+			return
+		}
+	}
+	t.Errorf("instances should be %v, got %v", expected, instanceURLs(c))
+}
+
+func TestHostInstancer(t *testing.T) {
+	r := &fakeResolver{}
+	r.set([]string{"10.0.0.2", "10.0.0.1"}, nil)
+	i := NewHostInstancer("foo.default.svc.cluster.local", 8080, WithResolver(r), RefreshInterval(10*time.Millisecond))
+	defer i.Stop()
+	c := balancer.NewClient("GET", i, "/", kithttp.EncodeJSONRequest, nopDecoder)
+	defer c.Close()
+	if urls := instanceURLs(c); !reflect.DeepEqual(urls, []string{"http://10.0.0.1:8080", "http://10.0.0.2:8080"}) {
+		t.Errorf("instances should be available once the client is created, got %v", urls)
+	}
+
+	r.set([]string{"10.0.0.3", "10.0.0.1"}, nil)
+	waitFor(t, c, []string{"http://10.0.0.1:8080", "http://10.0.0.3:8080"})
+
+	r.set(nil, nil)
+	waitFor(t, c, nil)
+}
+
+func TestSRVInstancer(t *testing.T) {
+	r := &fakeResolver{}
+	r.set(nil, []*net.SRV{{Target: "foo-0.foo.default.svc.cluster.local.", Port: 8080}})
+	i := NewSRVInstancer("http", "tcp", "foo.default.svc.cluster.local", WithResolver(r), Scheme("https"), RefreshInterval(10*time.Millisecond))
+	defer i.Stop()
+	c := balancer.NewClient("GET", i, "/", kithttp.EncodeJSONRequest, nopDecoder)
+	defer c.Close()
+	waitFor(t, c, []string{"https://foo-0.foo.default.svc.cluster.local:8080"})
+}
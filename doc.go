@@ -3,18 +3,18 @@
 //
 // Kitty has an opinion on:
 //
-// * transports: HTTP only (additional transports can be added as long as they implement kitty.Transport),
+// * transports: HTTP (additional transports can be added as long as they implement kitty.Transport, a gRPC transport is available in a sub-package),
 // * errors: an error may be Retryable (e.g. 5XX status codes) or not (e.g. 4XX status codes).
 //
 // Kitty has no opinion on:
 //
 // * logging: no logs are generated by default, you can plug your logger and it will get additional context,
 //
-// * packages: kitty only imports go-kit and the standard library,
+// * packages: the kitty package only imports go-kit and the standard library (sub-packages import their own dependencies),
 //
 // * routers: you can use any router (Gorilla Mux works out of the box, other routers can easily be plugged),
 //
 // * encoding: use whatever encoding you want (JSON, messagepack, protobuf, ...),
 //
-// * monitoring, metrics and tracing: use Istio or a sidecar process.
+// * monitoring, metrics and tracing: use Istio, a sidecar process or a middleware (Prometheus and tracing middlewares are available in sub-packages).
 package kitty
@@ -3,6 +3,7 @@ package kitty
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/go-kit/kit/endpoint"
 	kithttp "github.com/go-kit/kit/transport/http"
@@ -15,6 +16,8 @@ type httpendpoint struct {
 	endpoint     endpoint.Endpoint
 	decoder      kithttp.DecodeRequestFunc
 	encoder      kithttp.EncodeResponseFunc
+	errorEncoder kithttp.ErrorEncoder
+	timeout      time.Duration
 	options      []kithttp.ServerOption
 }
 
@@ -38,13 +41,27 @@ func (t *HTTPTransport) Endpoint(method, path string, ep endpoint.Endpoint, opts
 	return t
 }
 
+// populateRouteContext adds the method and path template of the endpoint to the context.
+func (e *httpendpoint) populateRouteContext(ctx context.Context, _ *http.Request) context.Context {
+	ctx = context.WithValue(ctx, routeMethodKey, e.method)
+	return context.WithValue(ctx, routePathKey, e.path)
+}
+
+// Route returns the method and path template (e.g. "/users/{id}" with gorilla) of the endpoint being called.
+// Contrary to the request path, the path template has a low cardinality, and can be used as a metrics label.
+func Route(ctx context.Context) (method, path string) {
+	method, _ = ctx.Value(routeMethodKey).(string)
+	path, _ = ctx.Value(routePathKey).(string)
+	return
+}
+
 type decoderError struct {
 	error
 }
 
 func (e decoderError) StatusCode() int {
-	if err, ok := e.error.(kithttp.StatusCoder); ok {
-		return err.StatusCode()
+	if code, ok := LookupStatusCode(e.error); ok {
+		return code
 	}
 	return http.StatusBadRequest
 }
@@ -73,6 +90,24 @@ func Encoder(enc kithttp.EncodeResponseFunc) HTTPEndpointOption {
 	}
 }
 
+// ErrorEncoder defines the error encoder for a HTTP endpoint (e.g. EncodeProblem).
+// If none is provided, Config.EncodeError is used.
+func ErrorEncoder(enc kithttp.ErrorEncoder) HTTPEndpointOption {
+	return func(e *httpendpoint) *httpendpoint {
+		e.errorEncoder = enc
+		return e
+	}
+}
+
+// Timeout bounds the execution time of a HTTP endpoint (see TimeoutEndpoint).
+// If the endpoint does not return in time, a retryable 504 error is returned.
+func Timeout(d time.Duration) HTTPEndpointOption {
+	return func(e *httpendpoint) *httpendpoint {
+		e.timeout = d
+		return e
+	}
+}
+
 // ServerOptions defines a liste of go-kit ServerOption to be used by a HTTP endpoint.
 func ServerOptions(opts ...kithttp.ServerOption) HTTPEndpointOption {
 	return func(e *httpendpoint) *httpendpoint {
@@ -1,8 +1,18 @@
 package kitty
 
 import (
-	"net/http"
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	kithttp "github.com/go-kit/kit/transport/http"
 )
 
 // Retryabler defines an error that may be temporary. A function returning a retryable error may be executed again.
@@ -12,26 +22,73 @@ type Retryabler interface {
 }
 
 // IsRetryable checks if an error is retryable (i.e. implements Retryabler and Retryable returns true).
-// Retryable errors may be wrapped using github.com/pkg/errors.
+// Retryable errors may be wrapped using github.com/pkg/errors or fmt.Errorf with %w.
 // If the error is nil or does not implement Retryabler, false is returned.
 func IsRetryable(err error) bool {
-	type causer interface {
-		Cause() error
-	}
-
-	for err != nil {
+	for ; err != nil; err = unwrap(err) {
 		if retry, ok := err.(Retryabler); ok {
 			return retry.Retryable()
 		}
-		cause, ok := err.(causer)
-		if !ok {
-			break
-		}
-		err = cause.Cause()
 	}
 	return false
 }
 
+// unwrap returns the error wrapped by err, using either Cause (github.com/pkg/errors) or Unwrap (Go 1.13).
+func unwrap(err error) error {
+	switch e := err.(type) {
+	case interface{ Cause() error }:
+		return e.Cause()
+	case interface{ Unwrap() error }:
+		return e.Unwrap()
+	}
+	return nil
+}
+
+// forwarder is implemented by wrappers forwarding the status code of the error they wrap.
+type forwarder interface {
+	forwardsStatusCode()
+}
+
+// LookupStatusCode returns the status code of the first error of the chain implementing kithttp.StatusCoder,
+// and false if no error of the chain defines a status code.
+// Errors may be wrapped using github.com/pkg/errors or fmt.Errorf with %w.
+func LookupStatusCode(err error) (int, bool) {
+	for ; err != nil; err = unwrap(err) {
+		if _, ok := err.(forwarder); ok {
+			continue
+		}
+		if sc, ok := err.(kithttp.StatusCoder); ok {
+			return sc.StatusCode(), true
+		}
+	}
+	return 0, false
+}
+
+// ErrorStatusCode returns the HTTP status code of an error (see kithttp.StatusCoder), or 500 if none is defined.
+// Errors may be wrapped using github.com/pkg/errors or fmt.Errorf with %w.
+func ErrorStatusCode(err error) int {
+	if code, ok := LookupStatusCode(err); ok {
+		return code
+	}
+	return http.StatusInternalServerError
+}
+
+// ErrorHeaders returns the HTTP headers of an error (see kithttp.Headerer).
+// Errors may be wrapped using github.com/pkg/errors or fmt.Errorf with %w. Outer errors take precedence.
+func ErrorHeaders(err error) http.Header {
+	h := http.Header{}
+	for ; err != nil; err = unwrap(err) {
+		if hr, ok := err.(kithttp.Headerer); ok {
+			for k, v := range hr.Headers() {
+				if _, ok := h[k]; !ok {
+					h[k] = v
+				}
+			}
+		}
+	}
+	return h
+}
+
 type retryableError struct {
 	error
 }
@@ -40,10 +97,27 @@ func (e retryableError) Cause() error {
 	return e.error
 }
 
+// Unwrap returns the wrapped error (see errors.As).
+func (e retryableError) Unwrap() error {
+	return e.error
+}
+
 func (retryableError) Retryable() bool {
 	return true
 }
 
+// StatusCode forwards the status code of the wrapped error.
+func (e retryableError) StatusCode() int {
+	return ErrorStatusCode(e.error)
+}
+
+// Headers forwards the headers of the wrapped error.
+func (e retryableError) Headers() http.Header {
+	return ErrorHeaders(e.error)
+}
+
+func (retryableError) forwardsStatusCode() {}
+
 var _ error = retryableError{}
 var _ Retryabler = retryableError{}
 
@@ -52,13 +126,175 @@ func Retryable(err error) error {
 	return retryableError{error: err}
 }
 
+type permanentError struct {
+	error
+}
+
+func (e permanentError) Cause() error {
+	return e.error
+}
+
+// Unwrap returns the wrapped error (see errors.As).
+func (e permanentError) Unwrap() error {
+	return e.error
+}
+
+func (permanentError) Retryable() bool {
+	return false
+}
+
+// StatusCode forwards the status code of the wrapped error.
+func (e permanentError) StatusCode() int {
+	return ErrorStatusCode(e.error)
+}
+
+// Headers forwards the headers of the wrapped error.
+func (e permanentError) Headers() http.Header {
+	return ErrorHeaders(e.error)
+}
+
+func (permanentError) forwardsStatusCode() {}
+
+var _ Retryabler = permanentError{}
+
+// Permanent defines an error as non retryable, even if the wrapped error is retryable (e.g. a 503 error).
+// It stops retries (see the backoff middleware).
+func Permanent(err error) error {
+	return permanentError{error: err}
+}
+
+// DefaultErrorBodyLimit is the default maximum number of bytes of the response body kept by HTTPError.
+const DefaultErrorBodyLimit = 1024
+
 // HTTPError builds an error based on a http.Response. If status code is < 300 or 304, nil is returned.
 // 429, 5XX errors are Retryable.
+// If the first DefaultErrorBodyLimit bytes of the response body contain RFC 7807 problem details, a *Problem is returned,
+// otherwise a *ResponseError is returned, with the first DefaultErrorBodyLimit bytes of the body.
+// In both cases, the *ResponseError can be retrieved with errors.As, and the body can still be read.
 func HTTPError(resp *http.Response) error {
+	return HTTPErrorWithLimit(resp, DefaultErrorBodyLimit)
+}
+
+// HTTPErrorWithLimit builds an error based on a http.Response, like HTTPError,
+// reading at most limit bytes of the response body.
+func HTTPErrorWithLimit(resp *http.Response, limit int) error {
 	if resp.StatusCode < 300 || resp.StatusCode == http.StatusNotModified {
 		return nil
 	}
-	return httpError(resp.StatusCode)
+	rerr := &ResponseError{Status: resp.StatusCode, Header: resp.Header, Body: peekBody(resp, limit)}
+	if p := parseProblem(rerr); p != nil {
+		return p
+	}
+	return rerr
+}
+
+// peekBody reads the first limit bytes of the response body, and restores the body.
+func peekBody(resp *http.Response, limit int) []byte {
+	if resp.Body == nil || limit <= 0 {
+		return nil
+	}
+	b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, int64(limit)))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{Reader: io.MultiReader(bytes.NewReader(b), resp.Body), Closer: resp.Body}
+	return b
+}
+
+// ResponseError is the error returned by HTTPError for responses without problem details (see Problem.Response).
+// It can be retrieved with errors.As, even if it has been marked as retryable (or not) by a client classifier.
+type ResponseError struct {
+	Status int
+	// Header holds the response headers.
+	Header http.Header
+	// Body holds the first bytes of the response body (see HTTPErrorWithLimit).
+	Body []byte
+}
+
+var _ Retryabler = &ResponseError{}
+var _ kithttp.StatusCoder = &ResponseError{}
+var _ kithttp.Headerer = &ResponseError{}
+
+// Error implements error.
+func (err *ResponseError) Error() string {
+	return httpError(err.Status).Error()
+}
+
+// StatusCode implements kithttp.StatusCoder.
+func (err *ResponseError) StatusCode() int {
+	return err.Status
+}
+
+// Headers implements kithttp.Headerer.
+// Only the relevant headers are returned (Retry-After & WWW-Authenticate).
+func (err *ResponseError) Headers() http.Header {
+	return relevantHeaders(err.Header)
+}
+
+// Retryable implements Retryabler. 429 & 5XX errors are retryable.
+func (err *ResponseError) Retryable() bool {
+	return httpError(err.Status).Retryable()
+}
+
+// Cause implements Retryabler.
+func (err *ResponseError) Cause() error {
+	return httpError(err.Status).Cause()
+}
+
+// relevantHeaders returns the response headers returned by kithttp.Headerer.
+// Other headers (e.g. Content-Type) should not be returned by kithttp.Headerer, as they could be copied to
+// the response of an endpoint returning the error.
+func relevantHeaders(h http.Header) http.Header {
+	relevant := http.Header{}
+	for _, k := range []string{"Retry-After", "WWW-Authenticate"} {
+		if v, ok := h[k]; ok {
+			relevant[k] = v
+		}
+	}
+	return relevant
+}
+
+// RetryAfter returns the delay requested by the Retry-After header of an error (see kithttp.Headerer),
+// given either in seconds or as a HTTP date.
+// Errors may be wrapped using github.com/pkg/errors or fmt.Errorf with %w.
+func RetryAfter(err error) (time.Duration, bool) {
+	if v := ErrorHeaders(err).Get("Retry-After"); v != "" {
+		return parseRetryAfter(v)
+	}
+	return 0, false
+}
+
+// parseRetryAfter parses the value of a Retry-After header.
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if d := time.Until(t); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+// parseProblem parses the problem details of the body of a response error.
+// nil is returned if the response is not a valid problem (including problems truncated by the body limit).
+func parseProblem(rerr *ResponseError) *Problem {
+	if ct, _, err := mime.ParseMediaType(rerr.Header.Get("Content-Type")); err != nil || ct != ProblemContentType {
+		return nil
+	}
+	p := &Problem{}
+	if err := json.Unmarshal(rerr.Body, p); err != nil {
+		return nil
+	}
+	p.Status = rerr.Status
+	p.Response = rerr
+	return p
 }
 
 type httpError int
@@ -90,3 +326,25 @@ func (err httpError) Retryable() bool {
 func (err httpError) Cause() error {
 	return errors.New(err.Error())
 }
+
+// EncodeError is the default error encoder. It behaves like the go-kit DefaultErrorEncoder,
+// but the status code and headers of wrapped errors are used (see ErrorStatusCode and ErrorHeaders).
+func EncodeError(ctx context.Context, err error, w http.ResponseWriter) {
+	if _, ok := err.(json.Marshaler); !ok {
+		err = resolvedError{error: err}
+	}
+	kithttp.DefaultErrorEncoder(ctx, err, w)
+}
+
+// resolvedError forwards the status code and headers of a wrapped error.
+type resolvedError struct {
+	error
+}
+
+func (e resolvedError) StatusCode() int {
+	return ErrorStatusCode(e.error)
+}
+
+func (e resolvedError) Headers() http.Header {
+	return ErrorHeaders(e.error)
+}
@@ -1,9 +1,17 @@
 package kitty
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	kithttp "github.com/go-kit/kit/transport/http"
 )
 
 func TestRetryable(t *testing.T) {
@@ -25,3 +33,81 @@ func TestRetryable(t *testing.T) {
 		}
 	}
 }
+
+func TestRetryAfter(t *testing.T) {
+	tcs := []struct {
+		header string
+		ok     bool
+		min    time.Duration
+	}{
+		{header: "", ok: false},
+		{header: "10", ok: true, min: 10 * time.Second},
+		{header: "-1", ok: false},
+		{header: time.Now().Add(time.Minute).UTC().Format(http.TimeFormat), ok: true, min: 58 * time.Second},
+		{header: "foo", ok: false},
+	}
+	for _, tc := range tcs {
+		resp := &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}}
+		if tc.header != "" {
+			resp.Header.Set("Retry-After", tc.header)
+		}
+		resp.Header.Set("Content-Type", "text/plain")
+		err := HTTPError(resp)
+		if h := err.(kithttp.Headerer).Headers(); h.Get("Content-Type") != "" {
+			t.Error("HTTPError should only keep relevant headers")
+		}
+		d, ok := RetryAfter(Retryable(err))
+		if ok != tc.ok || d < tc.min || d > tc.min+2*time.Second {
+			t.Errorf("RetryAfter(%s) returned %s/%t", tc.header, d, ok)
+		}
+	}
+}
+
+func TestHTTPErrorBody(t *testing.T) {
+	resp := &http.Response{StatusCode: http.StatusBadRequest, Body: ioutil.NopCloser(strings.NewReader("invalid name"))}
+	err := HTTPErrorWithLimit(resp, 7)
+	if rerr, ok := err.(*ResponseError); !ok || string(rerr.Body) != "invalid" {
+		t.Errorf("HTTPErrorWithLimit should keep the first bytes of the body, got %+v", err)
+	}
+	if b, _ := ioutil.ReadAll(resp.Body); string(b) != "invalid name" {
+		t.Errorf("the body should still be readable, got %s", b)
+	}
+}
+
+func TestWrappedErrors(t *testing.T) {
+	err := fmt.Errorf("get user: %w", NotFound(errors.New("user not found")))
+	if code := ErrorStatusCode(err); code != http.StatusNotFound {
+		t.Errorf("ErrorStatusCode should return 404, got %d", code)
+	}
+	if IsRetryable(err) {
+		t.Error("a NotFound error should not be retryable")
+	}
+	if code := Retryable(err).(kithttp.StatusCoder).StatusCode(); code != http.StatusNotFound {
+		t.Errorf("Retryable should forward the status code, got %d", code)
+	}
+	if code := ErrorStatusCode(Retryable(errors.New("foo"))); code != http.StatusInternalServerError {
+		t.Errorf("ErrorStatusCode should return 500 for errors without status code, got %d", code)
+	}
+
+	err = fmt.Errorf("get user: %w", WithRetryAfter(Unavailable(errors.New("db down")), 1500*time.Millisecond))
+	if !IsRetryable(err) {
+		t.Error("an Unavailable error wrapped with fmt.Errorf should be retryable")
+	}
+	if IsRetryable(Permanent(err)) {
+		t.Error("a Permanent error should not be retryable")
+	}
+	if d, ok := RetryAfter(err); !ok || d != 2*time.Second {
+		t.Errorf("RetryAfter should return 2s, got %s", d)
+	}
+	for _, d := range []time.Duration{-time.Second, 0, time.Millisecond} {
+		if ra, ok := RetryAfter(WithRetryAfter(err, d)); !ok || ra != time.Second {
+			t.Errorf("WithRetryAfter(%s) should set a 1s delay, got %s", d, ra)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	EncodeError(context.TODO(), Permanent(err), rec)
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "2" {
+		t.Errorf("EncodeError should use the status code and headers of wrapped errors, got %d/%v", rec.Code, rec.Header())
+	}
+}
@@ -0,0 +1,195 @@
+package fallback
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/objenious/kitty"
+	"github.com/sony/gobreaker"
+)
+
+// Condition decides if an error triggers the fallback.
+type Condition func(err error) bool
+
+// OpenBreaker triggers the fallback if a circuit breaker is open (see the circuitbreaker package).
+func OpenBreaker(err error) bool {
+	return errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests)
+}
+
+// Retryable triggers the fallback on retryable errors (see kitty.IsRetryable), including open circuit breakers.
+func Retryable(err error) bool {
+	return kitty.IsRetryable(err)
+}
+
+// Timeout triggers the fallback on timeouts.
+func Timeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr) && nerr.Timeout()
+}
+
+// DegradedFunc is called when a fallback response is returned, with the error of the call.
+type DegradedFunc func(ctx context.Context, err error)
+
+// LogDegraded logs degraded responses with the logger of the context (see kitty.Logger).
+func LogDegraded(ctx context.Context, err error) {
+	_ = kitty.LogMessage(ctx, "degraded response", "error", err)
+}
+
+// config holds the configuration of a fallback middleware.
+type config struct {
+	conditions []Condition
+	onDegraded DegradedFunc
+	maxEntries int
+}
+
+// Option is an option for fallback middlewares.
+type Option func(*config) *config
+
+// On defines the errors triggering the fallback (default: Retryable).
+func On(conditions ...Condition) Option {
+	return func(c *config) *config {
+		c.conditions = conditions
+		return c
+	}
+}
+
+// OnDegraded defines the function called when a fallback response is returned (default: LogDegraded),
+// e.g. to count degraded responses. A nil function disables notifications.
+func OnDegraded(fn DegradedFunc) Option {
+	return func(c *config) *config {
+		c.onDegraded = fn
+		return c
+	}
+}
+
+// MaxEntries defines the maximum number of responses kept by a NewCache middleware (default: 1000).
+func MaxEntries(n int) Option {
+	return func(c *config) *config {
+		c.maxEntries = n
+		return c
+	}
+}
+
+func newConfig(opts []Option) *config {
+	cfg := &config{conditions: []Condition{Retryable}, onDegraded: LogDegraded, maxEntries: 1000}
+	for _, opt := range opts {
+		cfg = opt(cfg)
+	}
+	return cfg
+}
+
+// triggers checks if an error triggers the fallback.
+func (cfg *config) triggers(err error) bool {
+	if err == nil {
+		return false
+	}
+	for _, cond := range cfg.conditions {
+		if cond(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// degraded marks the call as degraded.
+func (cfg *config) degraded(ctx context.Context, err error) {
+	if marker, ok := ctx.Value(degradedKey).(*int32); ok {
+		atomic.StoreInt32(marker, 1)
+	}
+	if cfg.onDegraded != nil {
+		cfg.onDegraded(ctx, err)
+	}
+}
+
+type contextKey int
+
+// context key for the degraded marker
+const degradedKey contextKey = iota
+
+// Track adds a degraded marker to the context, so that callers can check if a fallback response
+// was returned (see Degraded).
+func Track(ctx context.Context) context.Context {
+	return context.WithValue(ctx, degradedKey, new(int32))
+}
+
+// Degraded checks if a fallback response was returned to a call made with a context created by Track.
+func Degraded(ctx context.Context) bool {
+	marker, ok := ctx.Value(degradedKey).(*int32)
+	return ok && atomic.LoadInt32(marker) == 1
+}
+
+// NewFallback creates a middleware calling the fallback endpoint when a call fails.
+// If the fallback endpoint fails too, the error of the call is returned.
+func NewFallback(fallback endpoint.Endpoint, opts ...Option) endpoint.Middleware {
+	cfg := newConfig(opts)
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			response, err := next(ctx, request)
+			if !cfg.triggers(err) {
+				return response, err
+			}
+			fbresponse, fberr := fallback(ctx, request)
+			if fberr != nil {
+				return response, err
+			}
+			cfg.degraded(ctx, err)
+			return fbresponse, nil
+		}
+	}
+}
+
+// NewStatic creates a middleware returning a static response when a call fails.
+func NewStatic(response interface{}, opts ...Option) endpoint.Middleware {
+	return NewFallback(func(context.Context, interface{}) (interface{}, error) {
+		return response, nil
+	}, opts...)
+}
+
+// KeyFunc returns the key of a request, used to cache responses.
+type KeyFunc func(ctx context.Context, request interface{}) string
+
+// errNotCached is returned by the cache fallback if no response was cached for a request.
+var errNotCached = errors.New("no cached response")
+
+// NewCache creates a middleware keeping the last successful response for each request key,
+// and returning it when a call with the same key fails.
+// When the maximum number of entries is reached, a random entry is removed.
+func NewCache(key KeyFunc, opts ...Option) endpoint.Middleware {
+	cfg := newConfig(opts)
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		// responses are cached per endpoint
+		var mu sync.Mutex
+		cache := map[string]interface{}{}
+		fallback := NewFallback(func(ctx context.Context, request interface{}) (interface{}, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if response, ok := cache[key(ctx, request)]; ok {
+				return response, nil
+			}
+			return nil, errNotCached
+		}, opts...)
+		return fallback(func(ctx context.Context, request interface{}) (interface{}, error) {
+			response, err := next(ctx, request)
+			if err == nil {
+				k := key(ctx, request)
+				mu.Lock()
+				if _, ok := cache[k]; !ok && len(cache) >= cfg.maxEntries {
+					for old := range cache {
+						delete(cache, old)
+						break
+					}
+				}
+				cache[k] = response
+				mu.Unlock()
+			}
+			return response, err
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package fallback
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/objenious/kitty"
+	"github.com/sony/gobreaker"
+)
+
+func TestStatic(t *testing.T) {
+	var degraded []error
+	m := NewStatic("static", OnDegraded(func(_ context.Context, err error) { degraded = append(degraded, err) }))
+	tcs := []struct {
+		err      error
+		response interface{}
+	}{
+		{err: nil, response: "OK"},
+		{err: kitty.Retryable(gobreaker.ErrOpenState), response: "static"},
+		{err: kitty.Unavailable(errors.New("unavailable")), response: "static"},
+		{err: kitty.NotFound(errors.New("not found")), response: nil},
+	}
+	for _, tc := range tcs {
+		e := m(func(context.Context, interface{}) (interface{}, error) {
+			if tc.err != nil {
+				return nil, tc.err
+			}
+			return "OK", nil
+		})
+		ctx := Track(context.TODO())
+		res, _ := e(ctx, nil)
+		if res != tc.response {
+			t.Errorf("with error %v, the response should be %v, got %v", tc.err, tc.response, res)
+		}
+		if Degraded(ctx) != (tc.response == "static") {
+			t.Errorf("with error %v, the response should be marked as degraded", tc.err)
+		}
+	}
+	if len(degraded) != 2 {
+		t.Errorf("2 degraded responses should have been notified, got %d", len(degraded))
+	}
+}
+
+func TestConditions(t *testing.T) {
+	e := NewStatic("static", On(OpenBreaker))(func(context.Context, interface{}) (interface{}, error) {
+		return nil, kitty.Unavailable(errors.New("unavailable"))
+	})
+	if _, err := e(context.TODO(), nil); err == nil {
+		t.Error("only open circuit breakers should trigger the fallback")
+	}
+	if !Timeout(context.DeadlineExceeded) || Timeout(errors.New("foo")) {
+		t.Error("Timeout should only match timeouts")
+	}
+}
+
+func TestCache(t *testing.T) {
+	fail := false
+	var e endpoint.Endpoint = func(_ context.Context, request interface{}) (interface{}, error) {
+		if fail {
+			return nil, kitty.Unavailable(errors.New("unavailable"))
+		}
+		return "response " + request.(string), nil
+	}
+	e = NewCache(func(_ context.Context, request interface{}) string { return request.(string) }, OnDegraded(nil))(e)
+	_, _ = e(context.TODO(), "a")
+	fail = true
+	if res, err := e(context.TODO(), "a"); err != nil || res != "response a" {
+		t.Errorf("the cached response should be returned, got %v/%v", res, err)
+	}
+	if _, err := e(context.TODO(), "b"); err == nil {
+		t.Error("without cached response, the error should be returned")
+	}
+}
+
+func TestCachePerEndpoint(t *testing.T) {
+	m := NewCache(func(context.Context, interface{}) string { return "key" }, OnDegraded(nil))
+	fail := false
+	mkEndpoint := func(name string) endpoint.Endpoint {
+		return m(func(context.Context, interface{}) (interface{}, error) {
+			if fail {
+				return nil, kitty.Unavailable(errors.New("unavailable"))
+			}
+			return name, nil
+		})
+	}
+	foo, bar := mkEndpoint("foo"), mkEndpoint("bar")
+	_, _ = foo(context.TODO(), nil)
+	_, _ = bar(context.TODO(), nil)
+	fail = true
+	if res, _ := foo(context.TODO(), nil); res != "foo" {
+		t.Errorf("endpoints should not share cached responses, got %v", res)
+	}
+}
@@ -3,10 +3,12 @@ module github.com/objenious/kitty
 go 1.13
 
 require (
+	github.com/VividCortex/gohistogram v1.0.0 // indirect
 	github.com/cenkalti/backoff v2.2.1+incompatible
 	github.com/go-kit/kit v0.9.0
-	github.com/go-logfmt/logfmt v0.4.0 // indirect
-	github.com/go-stack/stack v1.8.0 // indirect
+	github.com/golang/protobuf v1.3.2
 	github.com/gorilla/mux v1.7.3
+	github.com/prometheus/client_golang v1.1.0
 	github.com/sony/gobreaker v0.4.1
+	google.golang.org/grpc v1.23.1
 )
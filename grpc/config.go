@@ -0,0 +1,12 @@
+package grpc
+
+// Config holds configuration info for a gRPC Transport.
+type Config struct {
+	// GRPCPort is the port the server will listen on (default: 8081).
+	GRPCPort int
+}
+
+// DefaultConfig defines the default config of a gRPC Transport.
+var DefaultConfig = Config{
+	GRPCPort: 8081,
+}
@@ -0,0 +1,102 @@
+package grpc
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/go-kit/kit/endpoint"
+	kitgrpc "github.com/go-kit/kit/transport/grpc"
+)
+
+// grpcendpoint encapsulates everything required to build
+// an endpoint hosted on a gRPC server.
+type grpcendpoint struct {
+	service, method string
+	request         reflect.Type
+	endpoint        endpoint.Endpoint
+	decoder         kitgrpc.DecodeRequestFunc
+	encoder         kitgrpc.EncodeResponseFunc
+	options         []kitgrpc.ServerOption
+}
+
+// EndpointOption is an option for a gRPC endpoint.
+type EndpointOption func(*grpcendpoint) *grpcendpoint
+
+// Endpoint registers an endpoint as the unary gRPC method /service/method.
+// request is the gRPC request message (e.g. &pb.FooRequest{}), a new message of the same type
+// is allocated for each call.
+// Unless specified, the gRPC request is passed as is to the endpoint, and the endpoint response
+// is sent as is to the gRPC client.
+func (t *Transport) Endpoint(service, method string, request interface{}, ep endpoint.Endpoint, opts ...EndpointOption) *Transport {
+	e := &grpcendpoint{
+		service:  service,
+		method:   method,
+		request:  reflect.TypeOf(request).Elem(),
+		endpoint: ep,
+		decoder:  nopDecoder,
+		encoder:  nopEncoder,
+	}
+	for _, opt := range opts {
+		e = opt(e)
+	}
+	t.endpoints = append(t.endpoints, e)
+	return t
+}
+
+// fullMethod returns the full gRPC method name, as used on the wire.
+func (e *grpcendpoint) fullMethod() string {
+	return "/" + e.service + "/" + e.method
+}
+
+// newRequest allocates a new gRPC request message.
+func (e *grpcendpoint) newRequest() interface{} {
+	return reflect.New(e.request).Interface()
+}
+
+type decoderError struct {
+	error
+}
+
+// Cause returns the decoding error.
+func (e decoderError) Cause() error {
+	return e.error
+}
+
+// Decoder defines the request decoder for a gRPC endpoint.
+// Decoding errors are returned to clients with an InvalidArgument status code.
+func Decoder(dec kitgrpc.DecodeRequestFunc) EndpointOption {
+	return func(e *grpcendpoint) *grpcendpoint {
+		e.decoder = func(ctx context.Context, r interface{}) (interface{}, error) {
+			request, err := dec(ctx, r)
+			if err != nil {
+				return nil, decoderError{error: err}
+			}
+			return request, nil
+		}
+		return e
+	}
+}
+
+// Encoder defines the response encoder for a gRPC endpoint.
+func Encoder(enc kitgrpc.EncodeResponseFunc) EndpointOption {
+	return func(e *grpcendpoint) *grpcendpoint {
+		e.encoder = enc
+		return e
+	}
+}
+
+// ServerOptions defines a list of go-kit ServerOption to be used by a gRPC endpoint.
+func ServerOptions(opts ...kitgrpc.ServerOption) EndpointOption {
+	return func(e *grpcendpoint) *grpcendpoint {
+		e.options = opts
+		return e
+	}
+}
+
+func nopDecoder(_ context.Context, r interface{}) (interface{}, error) {
+	return r, nil
+}
+
+func nopEncoder(_ context.Context, r interface{}) (interface{}, error) {
+	return r, nil
+}
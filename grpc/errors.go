@@ -0,0 +1,61 @@
+package grpc
+
+import (
+	"net/http"
+
+	"github.com/objenious/kitty"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// toStatus maps an endpoint error to a gRPC status error.
+// Errors that already carry a gRPC status are returned as is. Otherwise, the status code is
+// derived from the HTTP status code (see kitty.LookupStatusCode), or from kitty.IsRetryable.
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if _, ok := err.(decoderError); ok {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	if code, ok := kitty.LookupStatusCode(err); ok {
+		return status.Error(httpToCode(code), err.Error())
+	}
+	if kitty.IsRetryable(err) {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return status.Error(codes.Unknown, err.Error())
+}
+
+// httpToCode maps an HTTP status code to a gRPC code.
+func httpToCode(code int) codes.Code {
+	switch code {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable, http.StatusBadGateway:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	if code >= 500 {
+		return codes.Internal
+	}
+	return codes.Unknown
+}
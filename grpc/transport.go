@@ -0,0 +1,185 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/go-kit/kit/endpoint"
+	kitgrpc "github.com/go-kit/kit/transport/grpc"
+	"github.com/objenious/kitty"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// Transport defines a gRPC transport for a kitty Server.
+// Only unary methods are supported.
+type Transport struct {
+	cfg Config
+
+	opts     []kitgrpc.ServerOption
+	grpcopts []grpc.ServerOption
+
+	endpoints []*grpcendpoint
+
+	lis net.Listener
+	svr *grpc.Server
+}
+
+var _ kitty.Transport = &Transport{}
+
+// NewTransport creates a new gRPC transport, based on the specified config.
+func NewTransport(cfg Config) *Transport {
+	t := &Transport{
+		cfg: DefaultConfig,
+	}
+	if cfg.GRPCPort > 0 {
+		t.cfg.GRPCPort = cfg.GRPCPort
+	}
+	return t
+}
+
+// Options defines the list of go-kit grpc.ServerOption to be added to all endpoints.
+func (t *Transport) Options(opts ...kitgrpc.ServerOption) *Transport {
+	t.opts = opts
+	return t
+}
+
+// GRPCOptions defines the list of grpc.ServerOption used to create the gRPC server.
+func (t *Transport) GRPCOptions(opts ...grpc.ServerOption) *Transport {
+	t.grpcopts = opts
+	return t
+}
+
+// Listener defines the listener the server will accept connections on.
+// If none is provided, the server will listen on the port defined in the config.
+func (t *Transport) Listener(lis net.Listener) *Transport {
+	t.lis = lis
+	return t
+}
+
+// RegisterEndpoints registers all configured endpoints, wraps them with the m middleware.
+func (t *Transport) RegisterEndpoints(m endpoint.Middleware) error {
+	opts := []kitgrpc.ServerOption{
+		kitgrpc.ServerBefore(populateRequestContext),
+	}
+	opts = append(opts, t.opts...)
+
+	t.svr = grpc.NewServer(t.grpcopts...)
+	services := map[string]*grpc.ServiceDesc{}
+	var order []string
+	for _, ep := range t.endpoints {
+		sd, ok := services[ep.service]
+		if !ok {
+			sd = &grpc.ServiceDesc{
+				ServiceName: ep.service,
+				HandlerType: (*interface{})(nil),
+			}
+			services[ep.service] = sd
+			order = append(order, ep.service)
+		}
+		sd.Methods = append(sd.Methods, grpc.MethodDesc{
+			MethodName: ep.method,
+			Handler: makeHandler(ep, kitgrpc.NewServer(
+				m(ep.endpoint),
+				ep.decoder,
+				ep.encoder,
+				append(opts, ep.options...)...)),
+		})
+	}
+	for _, name := range order {
+		t.svr.RegisterService(services[name], t)
+	}
+	return nil
+}
+
+// makeHandler builds a gRPC method handler, calling a go-kit gRPC server.
+func makeHandler(ep *grpcendpoint, h kitgrpc.Handler) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		req := ep.newRequest()
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			ctx = context.WithValue(ctx, contextKeyMethod, ep.fullMethod())
+			_, resp, err := h.ServeGRPC(ctx, req)
+			return resp, toStatus(err)
+		}
+		if interceptor == nil {
+			return handler(ctx, req)
+		}
+		info := &grpc.UnaryServerInfo{Server: srv, FullMethod: ep.fullMethod()}
+		return interceptor(ctx, req, info, handler)
+	}
+}
+
+type contextKey int
+
+const (
+	contextKeyMethod contextKey = iota
+	contextKeyPeerAddr
+	contextKeyRequestID
+)
+
+// populateRequestContext adds the peer address and the request id found in gRPC metadata to the context.
+func populateRequestContext(ctx context.Context, md metadata.MD) context.Context {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		ctx = context.WithValue(ctx, contextKeyPeerAddr, p.Addr.String())
+	}
+	if ids := md.Get("x-request-id"); len(ids) > 0 {
+		ctx = context.WithValue(ctx, contextKeyRequestID, ids[0])
+	}
+	return ctx
+}
+
+var grpcLogkeys = map[string]interface{}{
+	"grpc-method":       contextKeyMethod,
+	"grpc-peer-addr":    contextKeyPeerAddr,
+	"grpc-x-request-id": contextKeyRequestID,
+}
+
+// LogKeys returns the list of name key to context key mappings.
+// Available keys are : grpc-method, grpc-peer-addr and grpc-x-request-id.
+func (t *Transport) LogKeys() map[string]interface{} {
+	return grpcLogkeys
+}
+
+// Start starts the gRPC server.
+func (t *Transport) Start(ctx context.Context) error {
+	lis := t.lis
+	if lis == nil {
+		var err error
+		lis, err = net.Listen("tcp", fmt.Sprintf(":%d", t.cfg.GRPCPort))
+		if err != nil {
+			return err
+		}
+		_ = kitty.LogMessage(ctx, fmt.Sprintf("Listening on port: %d", t.cfg.GRPCPort))
+	}
+	err := t.svr.Serve(lis)
+	if err != nil && err != grpc.ErrServerStopped {
+		return err
+	}
+	return nil
+}
+
+// Shutdown gracefully shutdowns the gRPC server.
+// Pending RPCs are aborted if ctx is done before they complete, and the error of ctx is returned.
+func (t *Transport) Shutdown(ctx context.Context) error {
+	if t.svr == nil {
+		return nil
+	}
+	done := make(chan struct{})
+	go func() {
+		t.svr.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		t.svr.Stop()
+		<-done
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,192 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/objenious/kitty"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestTransport(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	lis := bufconn.Listen(1024 * 1024)
+	tr := NewTransport(DefaultConfig).
+		Listener(lis).
+		Endpoint("kitty.Test", "Echo", &wrappers.StringValue{}, testEP, Decoder(decodeEcho)).
+		Endpoint("kitty.Test", "Fail", &wrappers.StringValue{}, failingEP)
+
+	middlewareCalled := false
+	logs := &testLogger{}
+	srv := kitty.NewServer(tr).
+		Logger(logs).
+		LogContext("grpc-method", "grpc-x-request-id").
+		Middlewares(func(e endpoint.Endpoint) endpoint.Endpoint {
+			return func(ctx context.Context, request interface{}) (interface{}, error) {
+				middlewareCalled = true
+				_ = kitty.LogMessage(ctx, "called")
+				return e(ctx, request)
+			}
+		})
+	exitError := make(chan error)
+	go func() {
+		exitError <- srv.Run(ctx)
+	}()
+
+	conn, err := grpc.DialContext(ctx, "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("grpc.Dial returned an error : %s", err)
+	}
+	defer conn.Close()
+
+	{
+		res := &wrappers.StringValue{}
+		callCtx := metadata.AppendToOutgoingContext(ctx, "x-request-id", "foo-id")
+		err := conn.Invoke(callCtx, "/kitty.Test/Echo", &wrappers.StringValue{Value: "foo"}, res)
+		if err != nil {
+			t.Errorf("Invoke returned an error : %s", err)
+		} else if res.Value != "foo" {
+			t.Errorf("Invoke returned invalid data : %+v", res)
+		}
+		if !middlewareCalled {
+			t.Error("the server middleware was not called")
+		}
+		logged := logs.String()
+		if !strings.Contains(logged, "grpc-method,/kitty.Test/Echo,") || !strings.Contains(logged, "grpc-x-request-id,foo-id,") {
+			t.Errorf("gRPC metadata should have been logged, got `%s`", logged)
+		}
+	}
+	{
+		err := conn.Invoke(ctx, "/kitty.Test/Echo", &wrappers.StringValue{}, &wrappers.StringValue{})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("A decoding error should return an InvalidArgument code, not %v", status.Code(err))
+		}
+	}
+	{
+		err := conn.Invoke(ctx, "/kitty.Test/Fail", &wrappers.StringValue{Value: "foo"}, &wrappers.StringValue{})
+		if status.Code(err) != codes.NotFound {
+			t.Errorf("A 404 error should return a NotFound code, not %v", status.Code(err))
+		}
+	}
+
+	cancel()
+	select {
+	case <-time.After(time.Second):
+		t.Error("Server.Run has not stopped after 1sec")
+	case err := <-exitError:
+		if err != nil && err != context.Canceled {
+			t.Errorf("Server.Run returned an error : %s", err)
+		}
+	}
+}
+
+func TestToStatus(t *testing.T) {
+	tcs := []struct {
+		err  error
+		code codes.Code
+	}{
+		{err: nil, code: codes.OK},
+		{err: status.Error(codes.AlreadyExists, "foo"), code: codes.AlreadyExists},
+		{err: statusError(http.StatusTooManyRequests), code: codes.ResourceExhausted},
+		{err: statusError(http.StatusInternalServerError), code: codes.Internal},
+		{err: kitty.Internal(fmt.Errorf("foo")), code: codes.Internal},
+		{err: kitty.Unavailable(fmt.Errorf("foo")), code: codes.Unavailable},
+		{err: kitty.WithStatus(fmt.Errorf("foo"), http.StatusBadGateway), code: codes.Unavailable},
+		{err: kitty.Retryable(fmt.Errorf("foo")), code: codes.Unavailable},
+		{err: fmt.Errorf("foo"), code: codes.Unknown},
+	}
+	for _, tc := range tcs {
+		if code := status.Code(toStatus(tc.err)); code != tc.code {
+			t.Errorf("toStatus(%v) returned %v instead of %v", tc.err, code, tc.code)
+		}
+	}
+}
+
+type statusError int
+
+func (e statusError) Error() string   { return http.StatusText(int(e)) }
+func (e statusError) StatusCode() int { return int(e) }
+
+func testEP(_ context.Context, req interface{}) (interface{}, error) {
+	return &wrappers.StringValue{Value: req.(string)}, nil
+}
+
+func failingEP(_ context.Context, _ interface{}) (interface{}, error) {
+	return nil, statusError(http.StatusNotFound)
+}
+
+func decodeEcho(_ context.Context, r interface{}) (interface{}, error) {
+	req := r.(*wrappers.StringValue)
+	if req.Value == "" {
+		return nil, fmt.Errorf("empty value")
+	}
+	return req.Value, nil
+}
+
+type testLogger struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (l *testLogger) Log(keyvals ...interface{}) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, kv := range keyvals {
+		fmt.Fprintf(&l.buf, "%v,", kv)
+	}
+	return nil
+}
+
+func (l *testLogger) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.String()
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	lis := bufconn.Listen(1024 * 1024)
+	started := make(chan struct{})
+	tr := NewTransport(DefaultConfig).
+		Listener(lis).
+		Endpoint("kitty.Test", "Block", &wrappers.StringValue{}, func(ctx context.Context, _ interface{}) (interface{}, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+	_ = tr.RegisterEndpoints(func(e endpoint.Endpoint) endpoint.Endpoint { return e })
+	go func() { _ = tr.Start(context.TODO()) }()
+
+	conn, err := grpc.DialContext(context.TODO(), "bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure())
+	if err != nil {
+		t.Fatalf("grpc.Dial returned an error : %s", err)
+	}
+	defer conn.Close()
+	go func() {
+		_ = conn.Invoke(context.TODO(), "/kitty.Test/Block", &wrappers.StringValue{Value: "foo"}, &wrappers.StringValue{})
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	if err := tr.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("a forced stop should return the context error, got %v", err)
+	}
+}
@@ -1,8 +1,14 @@
 package kitty
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Liveness defines the liveness handler.
@@ -17,8 +23,253 @@ func (t *HTTPTransport) Readiness(h http.HandlerFunc) *HTTPTransport {
 	return t
 }
 
+// readinessHandler calls the readiness handler, unless the transport is draining.
+func (t *HTTPTransport) readinessHandler(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&t.draining) == 1 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = io.WriteString(w, "draining")
+		return
+	}
+	t.readiness(w, r)
+}
+
 // defaultHealthcheck is a default health handler that returns a 200 status and an "OK" body
 func defaultHealthcheck(w http.ResponseWriter, _ *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	_, _ = io.WriteString(w, "OK")
 }
+
+// Checker checks the health of a dependency (database, cache, downstream service...).
+type Checker interface {
+	// Check returns an error if the dependency is not healthy.
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc is an adapter to use a function as a Checker.
+type CheckerFunc func(ctx context.Context) error
+
+// Check implements Checker.
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// healthcheck is a named Checker, with its options and cached result.
+type healthcheck struct {
+	name     string
+	checker  Checker
+	timeout  time.Duration
+	critical bool
+	cacheTTL time.Duration
+
+	// mu only protects the fields below, it is not held while the check runs.
+	mu      sync.Mutex
+	result  CheckResult
+	checked time.Time
+	flight  *flight
+}
+
+// flight is a run of a check, shared by concurrent callers.
+type flight struct {
+	done   chan struct{}
+	result CheckResult
+}
+
+// HealthCheckOption is an option for a health check.
+type HealthCheckOption func(*healthcheck) *healthcheck
+
+// CheckTimeout defines the maximum duration of a health check (default: 1s).
+func CheckTimeout(d time.Duration) HealthCheckOption {
+	return func(c *healthcheck) *healthcheck {
+		c.timeout = d
+		return c
+	}
+}
+
+// NonCritical defines a health check as non critical: it is reported, but its failure does not make the service unhealthy.
+func NonCritical() HealthCheckOption {
+	return func(c *healthcheck) *healthcheck {
+		c.critical = false
+		return c
+	}
+}
+
+// CheckCacheTTL defines how long the result of a health check is cached (default: no cache).
+func CheckCacheTTL(d time.Duration) HealthCheckOption {
+	return func(c *healthcheck) *healthcheck {
+		c.cacheTTL = d
+		return c
+	}
+}
+
+// HealthChecks is a registry of named health checks.
+// Its handlers can be used as liveness and readiness handlers, each one running a subset of the checks.
+type HealthChecks struct {
+	mu     sync.RWMutex
+	checks map[string]*healthcheck
+	names  []string
+}
+
+// NewHealthChecks creates an empty health check registry.
+func NewHealthChecks() *HealthChecks {
+	return &HealthChecks{checks: map[string]*healthcheck{}}
+}
+
+// Register registers a named health check.
+// Unless specified, checks are critical, time out after 1s, and their results are not cached.
+func (h *HealthChecks) Register(name string, c Checker, opts ...HealthCheckOption) *HealthChecks {
+	hc := &healthcheck{
+		name:     name,
+		checker:  c,
+		timeout:  time.Second,
+		critical: true,
+	}
+	for _, opt := range opts {
+		hc = opt(hc)
+	}
+	h.mu.Lock()
+	if _, ok := h.checks[name]; !ok {
+		h.names = append(h.names, name)
+	}
+	h.checks[name] = hc
+	h.mu.Unlock()
+	return h
+}
+
+// Health check statuses.
+const (
+	// HealthOK means that all checks succeeded.
+	HealthOK = "ok"
+	// HealthDegraded means that only non critical checks failed.
+	HealthDegraded = "degraded"
+	// HealthFailed means that a critical check failed.
+	HealthFailed = "failed"
+)
+
+// HealthReport is the result of a set of health checks.
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// CheckResult is the result of a health check.
+type CheckResult struct {
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	Latency  string `json:"latency"`
+	Error    string `json:"error,omitempty"`
+}
+
+var errUnknownCheck = errors.New("unknown health check")
+
+// Run runs the named checks (or all checks if no name is specified) concurrently.
+// Concurrent runs of a check (e.g. by readiness and liveness probes) share the same result.
+// Checks run with their own timeout: if ctx is done first, they are reported as failed.
+func (h *HealthChecks) Run(ctx context.Context, names ...string) HealthReport {
+	h.mu.RLock()
+	if len(names) == 0 {
+		names = h.names
+	}
+	checks := make([]*healthcheck, len(names))
+	for i, name := range names {
+		checks[i] = h.checks[name]
+	}
+	h.mu.RUnlock()
+
+	results := make([]CheckResult, len(names))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		if c == nil {
+			results[i] = CheckResult{Status: HealthFailed, Critical: true, Error: errUnknownCheck.Error()}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, c *healthcheck) {
+			defer wg.Done()
+			results[i] = c.run(ctx)
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := HealthReport{Status: HealthOK, Checks: make(map[string]CheckResult, len(names))}
+	for i, res := range results {
+		report.Checks[names[i]] = res
+		switch {
+		case res.Status == HealthOK:
+		case res.Critical:
+			report.Status = HealthFailed
+		case report.Status == HealthOK:
+			report.Status = HealthDegraded
+		}
+	}
+	return report
+}
+
+// Handler returns a health handler running the named checks (or all checks if no name is specified).
+// The handler returns a JSON report, with a 503 status if a critical check failed.
+func (h *HealthChecks) Handler(names ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := h.Run(r.Context(), names...)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if report.Status == HealthFailed {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+		_ = json.NewEncoder(w).Encode(report)
+	}
+}
+
+// run runs the check, or returns the cached result.
+// Concurrent callers share the same run of the check, so that a slow check does not queue probes.
+func (c *healthcheck) run(ctx context.Context) CheckResult {
+	c.mu.Lock()
+	if c.cacheTTL > 0 && !c.checked.IsZero() && time.Since(c.checked) < c.cacheTTL {
+		res := c.result
+		c.mu.Unlock()
+		return res
+	}
+	f := c.flight
+	if f == nil {
+		f = &flight{done: make(chan struct{})}
+		c.flight = f
+		go c.check(f)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.result
+	case <-ctx.Done():
+		return CheckResult{Status: HealthFailed, Critical: c.critical, Error: ctx.Err().Error()}
+	}
+}
+
+// check runs the check, with its own timeout (it is not canceled with the context of the first caller).
+func (c *healthcheck) check(f *flight) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	start := time.Now()
+	// the check is run in a goroutine, so that checks ignoring ctx can not block the handler.
+	res := make(chan error, 1)
+	go func() {
+		res <- c.checker.Check(ctx)
+	}()
+	var err error
+	select {
+	case err = <-res:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	f.result = CheckResult{Status: HealthOK, Critical: c.critical, Latency: time.Since(start).String()}
+	if err != nil {
+		f.result.Status = HealthFailed
+		f.result.Error = err.Error()
+	}
+	c.mu.Lock()
+	c.result = f.result
+	c.checked = time.Now()
+	c.flight = nil
+	c.mu.Unlock()
+	close(f.done)
+}
@@ -0,0 +1,104 @@
+package kitty
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealthChecks(t *testing.T) {
+	var dbCalls int32
+	hc := NewHealthChecks().
+		Register("db", CheckerFunc(func(context.Context) error {
+			atomic.AddInt32(&dbCalls, 1)
+			return nil
+		}), CheckCacheTTL(time.Minute)).
+		Register("cache", CheckerFunc(func(context.Context) error {
+			return errors.New("cache unavailable")
+		}), NonCritical()).
+		Register("slow", CheckerFunc(func(context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		}), CheckTimeout(10*time.Millisecond))
+
+	tcs := []struct {
+		names  []string
+		code   int
+		status string
+	}{
+		{names: []string{"db"}, code: http.StatusOK, status: HealthOK},
+		{names: []string{"db", "cache"}, code: http.StatusOK, status: HealthDegraded},
+		{names: []string{"db", "cache", "slow"}, code: http.StatusServiceUnavailable, status: HealthFailed},
+		{names: []string{"db", "unknown"}, code: http.StatusServiceUnavailable, status: HealthFailed},
+		{code: http.StatusServiceUnavailable, status: HealthFailed},
+	}
+	for _, tc := range tcs {
+		rec := httptest.NewRecorder()
+		start := time.Now()
+		hc.Handler(tc.names...)(rec, httptest.NewRequest("GET", "/readyz", nil))
+		if time.Since(start) > 500*time.Millisecond {
+			t.Errorf("checks %v should have timed out", tc.names)
+		}
+		if rec.Code != tc.code {
+			t.Errorf("checks %v returned a %d status instead of %d", tc.names, rec.Code, tc.code)
+		}
+		report := HealthReport{}
+		if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+			t.Errorf("unable to decode the health report : %s", err)
+			continue
+		}
+		if report.Status != tc.status {
+			t.Errorf("checks %v returned a %s status instead of %s", tc.names, report.Status, tc.status)
+		}
+		if len(tc.names) > 0 && len(report.Checks) != len(tc.names) {
+			t.Errorf("checks %v returned %d results", tc.names, len(report.Checks))
+		}
+	}
+	if report := hc.Run(context.TODO(), "cache"); report.Checks["cache"].Error != "cache unavailable" || report.Checks["cache"].Critical {
+		t.Errorf("invalid check result %+v", report.Checks["cache"])
+	}
+	if n := atomic.LoadInt32(&dbCalls); n != 1 {
+		t.Errorf("the db check result should have been cached, called %d times", n)
+	}
+}
+
+func TestHealthChecksConcurrentRuns(t *testing.T) {
+	var calls int32
+	hc := NewHealthChecks().
+		Register("slow", CheckerFunc(func(context.Context) error {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		}))
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if report := hc.Run(context.TODO()); report.Status != HealthOK {
+				t.Errorf("the check should succeed, got %+v", report)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("concurrent runs should share the same check, got %d calls", n)
+	}
+	if d := time.Since(start); d > 200*time.Millisecond {
+		t.Errorf("concurrent runs should not wait for each other, took %s", d)
+	}
+
+	// a caller giving up does not wait for the check
+	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Millisecond)
+	defer cancel()
+	if report := hc.Run(ctx); report.Status != HealthFailed {
+		t.Errorf("the check should fail when the context is done, got %+v", report)
+	}
+}
@@ -0,0 +1,177 @@
+package hedge
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+// config holds the configuration of the hedging middleware.
+type config struct {
+	delay       time.Duration
+	percentile  float64
+	maxRequests int
+	idempotent  bool
+}
+
+// Option is an option for the hedging middleware.
+type Option func(*config) *config
+
+// Delay defines the delay before sending a hedged request (default: 100ms).
+// When a latency percentile is defined, it is only used until enough latencies have been recorded.
+func Delay(d time.Duration) Option {
+	return func(c *config) *config {
+		c.delay = d
+		return c
+	}
+}
+
+// Percentile defines the delay before sending a hedged request as a percentile (e.g. 0.95) of the latencies
+// of the last successful calls.
+func Percentile(p float64) Option {
+	return func(c *config) *config {
+		c.percentile = p
+		return c
+	}
+}
+
+// MaxRequests defines the maximum number of concurrent requests per call, including the first one (default: 2).
+func MaxRequests(n int) Option {
+	return func(c *config) *config {
+		c.maxRequests = n
+		return c
+	}
+}
+
+// AllIdempotent defines all calls as idempotent, without having to mark them with Idempotent.
+func AllIdempotent() Option {
+	return func(c *config) *config {
+		c.idempotent = true
+		return c
+	}
+}
+
+type contextKey int
+
+// context key for the idempotency marker
+const idempotentKey contextKey = iota
+
+// Idempotent marks a call as idempotent. Only idempotent calls are hedged.
+func Idempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey, true)
+}
+
+// IsIdempotent checks if a call has been marked as idempotent.
+func IsIdempotent(ctx context.Context) bool {
+	idempotent, _ := ctx.Value(idempotentKey).(bool)
+	return idempotent
+}
+
+type result struct {
+	response interface{}
+	err      error
+}
+
+// NewHedge creates a hedging middleware, for latency-sensitive idempotent calls (see Idempotent).
+// If no response is received after a delay, another request is sent concurrently (up to MaxRequests),
+// and the first successful response is returned. Pending requests are then canceled, through their contexts.
+// If a request fails while no other request is pending, its error is returned (errors are not retried, see the backoff package).
+func NewHedge(opts ...Option) endpoint.Middleware {
+	cfg := &config{delay: 100 * time.Millisecond, maxRequests: 2}
+	for _, opt := range opts {
+		cfg = opt(cfg)
+	}
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		// latencies are recorded per endpoint
+		lat := &latencies{}
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			if cfg.maxRequests < 2 || !(cfg.idempotent || IsIdempotent(ctx)) {
+				return next(ctx, request)
+			}
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			results := make(chan result, cfg.maxRequests)
+			send := func() {
+				go func() {
+					start := time.Now()
+					response, err := next(ctx, request)
+					if err == nil {
+						lat.record(time.Since(start))
+					}
+					results <- result{response: response, err: err}
+				}()
+			}
+
+			delay := cfg.delay
+			if cfg.percentile > 0 {
+				if d, ok := lat.percentile(cfg.percentile); ok {
+					delay = d
+				}
+			}
+			timer := time.NewTimer(delay)
+			defer timer.Stop()
+			send()
+			sent, pending := 1, 1
+			for {
+				select {
+				case res := <-results:
+					pending--
+					if res.err == nil || pending == 0 {
+						return res.response, res.err
+					}
+				case <-timer.C:
+					if sent < cfg.maxRequests {
+						send()
+						sent++
+						pending++
+						timer.Reset(delay)
+					}
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+			}
+		}
+	}
+}
+
+// window is the number of latencies used to compute percentiles.
+const window = 100
+
+// latencies records the latencies of the last successful calls.
+type latencies struct {
+	mu      sync.Mutex
+	samples [window]time.Duration
+	count   int
+}
+
+func (l *latencies) record(d time.Duration) {
+	l.mu.Lock()
+	l.samples[l.count%window] = d
+	l.count++
+	l.mu.Unlock()
+}
+
+// percentile returns the p percentile of the recorded latencies, if enough latencies have been recorded.
+func (l *latencies) percentile(p float64) (time.Duration, bool) {
+	l.mu.Lock()
+	if l.count < window/10 {
+		l.mu.Unlock()
+		return 0, false
+	}
+	n := l.count
+	if n > window {
+		n = window
+	}
+	samples := make([]time.Duration, n)
+	copy(samples, l.samples[:n])
+	l.mu.Unlock()
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	i := int(p * float64(n))
+	if i >= n {
+		i = n - 1
+	}
+	return samples[i], true
+}
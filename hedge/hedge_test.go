@@ -0,0 +1,98 @@
+package hedge
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedge(t *testing.T) {
+	var calls, canceled int32
+	// the first request is slow, the others are fast
+	e := func(ctx context.Context, _ interface{}) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-ctx.Done()
+			atomic.AddInt32(&canceled, 1)
+			return nil, ctx.Err()
+		}
+		return "OK", nil
+	}
+	{
+		ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+		defer cancel()
+		_, err := NewHedge(Delay(10*time.Millisecond))(e)(ctx, nil)
+		if err == nil || atomic.LoadInt32(&calls) != 1 {
+			t.Error("non idempotent calls should not be hedged")
+		}
+	}
+	atomic.StoreInt32(&calls, 0)
+	atomic.StoreInt32(&canceled, 0)
+	{
+		ctx, cancel := context.WithTimeout(context.TODO(), time.Second)
+		defer cancel()
+		start := time.Now()
+		res, err := NewHedge(Delay(10*time.Millisecond))(e)(Idempotent(ctx), nil)
+		if err != nil || res != "OK" {
+			t.Errorf("the hedged request should succeed, got %v", err)
+		}
+		if time.Since(start) > 500*time.Millisecond {
+			t.Error("the hedged request should have been sent after the delay")
+		}
+		time.Sleep(10 * time.Millisecond)
+		if atomic.LoadInt32(&calls) != 2 || atomic.LoadInt32(&canceled) != 1 {
+			t.Errorf("the slow request should have been canceled, got %d calls and %d cancellations", calls, canceled)
+		}
+	}
+}
+
+func TestHedgeError(t *testing.T) {
+	var calls int32
+	e := func(context.Context, interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, errors.New("failure")
+	}
+	_, err := NewHedge(AllIdempotent(), MaxRequests(3))(e)(context.TODO(), nil)
+	if err == nil || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("an error should be returned if no other request is pending, got %v after %d calls", err, calls)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	l := &latencies{}
+	if _, ok := l.percentile(0.9); ok {
+		t.Error("no percentile should be computed without latencies")
+	}
+	for i := 1; i <= 200; i++ {
+		l.record(time.Duration(i) * time.Millisecond)
+	}
+	if d, ok := l.percentile(0.9); !ok || d != 191*time.Millisecond {
+		t.Errorf("the 0.9 percentile of the last 100 latencies should be 191ms, got %s", d)
+	}
+}
+
+func TestHedgeLatenciesPerEndpoint(t *testing.T) {
+	m := NewHedge(AllIdempotent(), Delay(time.Hour), Percentile(0.5))
+	slow := m(func(context.Context, interface{}) (interface{}, error) {
+		time.Sleep(5 * time.Millisecond)
+		return "OK", nil
+	})
+	for i := 0; i < window/10; i++ {
+		_, _ = slow(context.TODO(), nil)
+	}
+
+	// without latencies, the fast endpoint should use the configured delay, not the latencies of the slow endpoint
+	var calls int32
+	fast := m(func(ctx context.Context, _ interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	ctx, cancel := context.WithTimeout(context.TODO(), 200*time.Millisecond)
+	defer cancel()
+	_, _ = fast(ctx, nil)
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("the latencies of an endpoint should not be used by other endpoints, got %d calls", n)
+	}
+}
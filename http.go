@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/pprof"
+	"sync/atomic"
 
 	"github.com/go-kit/kit/endpoint"
 	kithttp "github.com/go-kit/kit/transport/http"
@@ -22,11 +23,18 @@ type HTTPTransport struct {
 	mux            Router
 	svr            *http.Server
 
+	adminhttpmiddleware func(http.Handler) http.Handler
+	adminmux            Router
+	adminsvr            *http.Server
+	adminhandlers       []adminhandler
+
 	liveness  http.HandlerFunc
 	readiness http.HandlerFunc
+	draining  int32
 }
 
 var _ Transport = &HTTPTransport{}
+var _ Drainer = &HTTPTransport{}
 
 // nopHTTPMiddleWare is the default HTTP middleware, and does nothing.
 func nopHTTPMiddleWare(h http.Handler) http.Handler {
@@ -47,11 +55,13 @@ func (t *HTTPTransport) HTTPMiddlewares(m ...func(http.Handler) http.Handler) *H
 // NewHTTPTransport creates a new HTTP transport, based on the specified config.
 func NewHTTPTransport(cfg Config) *HTTPTransport {
 	t := &HTTPTransport{
-		cfg:            DefaultConfig,
-		httpmiddleware: nopHTTPMiddleWare,
-		mux:            StdlibRouter(),
-		liveness:       defaultHealthcheck,
-		readiness:      defaultHealthcheck,
+		cfg:                 DefaultConfig,
+		httpmiddleware:      nopHTTPMiddleWare,
+		mux:                 StdlibRouter(),
+		adminhttpmiddleware: nopHTTPMiddleWare,
+		adminmux:            StdlibRouter(),
+		liveness:            defaultHealthcheck,
+		readiness:           defaultHealthcheck,
 	}
 	if cfg.HTTPPort > 0 {
 		t.cfg.HTTPPort = cfg.HTTPPort
@@ -63,21 +73,35 @@ func NewHTTPTransport(cfg Config) *HTTPTransport {
 		t.cfg.ReadinessCheckPath = cfg.ReadinessCheckPath
 	}
 	t.cfg.EnablePProf = cfg.EnablePProf
+	t.cfg.AdminHTTPPort = cfg.AdminHTTPPort
+	t.cfg.AdminHTTPAddress = cfg.AdminHTTPAddress
+	t.cfg.TLSCertFile = cfg.TLSCertFile
+	t.cfg.TLSKeyFile = cfg.TLSKeyFile
+	t.cfg.TLSClientCAFile = cfg.TLSClientCAFile
 	if cfg.EncodeResponse != nil {
 		t.cfg.EncodeResponse = cfg.EncodeResponse
 	}
+	if cfg.EncodeError != nil {
+		t.cfg.EncodeError = cfg.EncodeError
+	}
+	t.cfg.MinRequestTimeout = cfg.MinRequestTimeout
+	t.cfg.MaxRequestTimeout = cfg.MaxRequestTimeout
 	return t
 }
 
 // ServeHTTP implements http.Handler.
+// The deadline sent by kitty clients (see DeadlineHeader) is applied to the request context.
 func (t *HTTPTransport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	t.httpmiddleware(t.mux).ServeHTTP(w, r)
+	t.httpmiddleware(http.HandlerFunc(t.serveWithDeadline)).ServeHTTP(w, r)
 }
 
 // RegisterEndpoints registers all configured endpoints, wraps them with the m middleware.
 func (t *HTTPTransport) RegisterEndpoints(m endpoint.Middleware) error {
 	opts := []kithttp.ServerOption{
-		kithttp.ServerBefore(kithttp.PopulateRequestContext),
+		kithttp.ServerBefore(kithttp.PopulateRequestContext, populateRequestIDContext, populateTLSContext),
+	}
+	if t.cfg.EncodeError != nil {
+		opts = append(opts, kithttp.ServerErrorEncoder(t.cfg.EncodeError))
 	}
 	opts = append(opts, t.opts...)
 
@@ -87,34 +111,42 @@ func (t *HTTPTransport) RegisterEndpoints(m endpoint.Middleware) error {
 		if ep.encoder != nil {
 			encoder = ep.encoder
 		}
+		epopts := append([]kithttp.ServerOption{kithttp.ServerBefore(ep.populateRouteContext)}, opts...)
+		if ep.errorEncoder != nil {
+			epopts = append(epopts, kithttp.ServerErrorEncoder(ep.errorEncoder))
+		}
+		e := ep.endpoint
+		if ep.timeout > 0 {
+			e = TimeoutEndpoint(ep.timeout)(e)
+		}
 		t.mux.Handle(ep.method, ep.path,
 			kithttp.NewServer(
-				m(ep.endpoint),
+				m(e),
 				ep.decoder,
 				encoder,
-				append(opts, ep.options...)...))
+				append(epopts, ep.options...)...))
 	}
 
-	// register health handlers
-	t.mux.Handle("GET", t.cfg.LivenessCheckPath, t.liveness)
-	t.mux.Handle("GET", t.cfg.ReadinessCheckPath, t.readiness)
-
-	// register pprof handlers
-	registerPProf(t.cfg, t.mux)
+	// register health, pprof & admin handlers
+	t.registerAdminHandlers()
 	return nil
 }
 
 var httpLogkeys = map[string]interface{}{
-	"http-method":            kithttp.ContextKeyRequestMethod,
-	"http-uri":               kithttp.ContextKeyRequestURI,
-	"http-path":              kithttp.ContextKeyRequestPath,
-	"http-proto":             kithttp.ContextKeyRequestProto,
-	"http-requesthost":       kithttp.ContextKeyRequestHost,
-	"http-remote-addr":       kithttp.ContextKeyRequestRemoteAddr,
-	"http-x-forwarded-for":   kithttp.ContextKeyRequestXForwardedFor,
-	"http-x-forwarded-proto": kithttp.ContextKeyRequestXForwardedProto,
-	"http-user-agent":        kithttp.ContextKeyRequestUserAgent,
-	"http-x-request-id":      kithttp.ContextKeyRequestXRequestID,
+	"http-method":             kithttp.ContextKeyRequestMethod,
+	"http-uri":                kithttp.ContextKeyRequestURI,
+	"http-path":               kithttp.ContextKeyRequestPath,
+	"http-proto":              kithttp.ContextKeyRequestProto,
+	"http-requesthost":        kithttp.ContextKeyRequestHost,
+	"http-remote-addr":        kithttp.ContextKeyRequestRemoteAddr,
+	"http-x-forwarded-for":    kithttp.ContextKeyRequestXForwardedFor,
+	"http-x-forwarded-proto":  kithttp.ContextKeyRequestXForwardedProto,
+	"http-user-agent":         kithttp.ContextKeyRequestUserAgent,
+	"http-x-request-id":       kithttp.ContextKeyRequestXRequestID,
+	"http-tls-client-subject": tlsClientSubjectKey,
+	"http-route":              routePathKey,
+	"trace-id":                traceIDKey,
+	"span-id":                 spanIDKey,
 }
 
 // LogKeys returns the list of name key to context key mappings
@@ -122,23 +154,64 @@ func (t *HTTPTransport) LogKeys() map[string]interface{} {
 	return httpLogkeys
 }
 
-// Start starts the HTTP server.
+// Start starts the HTTP server (and the admin server, if an admin port is configured).
+// If a TLS certificate is configured, the server will use HTTPS.
 func (t *HTTPTransport) Start(ctx context.Context) error {
+	tlscfg, err := tlsConfig(t.cfg)
+	if err != nil {
+		return err
+	}
 	t.svr = &http.Server{
-		Handler: t,
-		Addr:    fmt.Sprintf(":%d", t.cfg.HTTPPort),
+		Handler:   t,
+		Addr:      fmt.Sprintf(":%d", t.cfg.HTTPPort),
+		TLSConfig: tlscfg,
+	}
+	errs := make(chan error, 2)
+	servers := 1
+	if t.cfg.AdminHTTPPort > 0 {
+		t.adminsvr = &http.Server{
+			Handler: t.adminmux,
+			Addr:    fmt.Sprintf("%s:%d", t.cfg.AdminHTTPAddress, t.cfg.AdminHTTPPort),
+		}
+		servers++
+		_ = LogMessage(ctx, fmt.Sprintf("Admin server listening on: %s", t.adminsvr.Addr))
+		go func() {
+			errs <- t.adminsvr.ListenAndServe()
+		}()
 	}
 	_ = LogMessage(ctx, fmt.Sprintf("Listening on port: %d", t.cfg.HTTPPort))
-	err := t.svr.ListenAndServe()
-	if err != nil && err != http.ErrServerClosed {
-		return err
+	go func() {
+		if tlscfg != nil {
+			errs <- t.svr.ListenAndServeTLS("", "")
+			return
+		}
+		errs <- t.svr.ListenAndServe()
+	}()
+	for i := 0; i < servers; i++ {
+		if err := <-errs; err != nil && err != http.ErrServerClosed {
+			return err
+		}
 	}
 	return nil
 }
 
-// Shutdown shutdowns the HTTP server
+// Drain makes the readiness handler return a 503 status, until the transport is shut down.
+func (t *HTTPTransport) Drain() {
+	atomic.StoreInt32(&t.draining, 1)
+}
+
+// Shutdown shutdowns the HTTP server (and the admin server, if an admin port is configured).
 func (t *HTTPTransport) Shutdown(ctx context.Context) error {
-	return t.svr.Shutdown(ctx)
+	var err error
+	if t.svr != nil {
+		err = t.svr.Shutdown(ctx)
+	}
+	if t.adminsvr != nil {
+		if aerr := t.adminsvr.Shutdown(ctx); aerr != nil && err == nil {
+			err = aerr
+		}
+	}
+	return err
 }
 
 func registerPProf(cfg Config, mux Router) {
@@ -96,3 +96,19 @@ func TestDefaultResponseEncode(t *testing.T) {
 		t.Errorf("different body expected: %s", body)
 	}
 }
+
+func TestRoute(t *testing.T) {
+	var method, path string
+	tr := NewHTTPTransport(DefaultConfig).
+		Endpoint("GET", "/test/", func(ctx context.Context, r interface{}) (interface{}, error) {
+			method, path = Route(ctx)
+			return nil, nil
+		})
+	_ = tr.RegisterEndpoints(func(e endpoint.Endpoint) endpoint.Endpoint {
+		return e
+	})
+	tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/test/foo", nil))
+	if method != "GET" || path != "/test/" {
+		t.Errorf("Route should return the route method and path template, got %s %s", method, path)
+	}
+}
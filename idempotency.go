@@ -0,0 +1,33 @@
+package kitty
+
+import (
+	"context"
+	"net/http"
+)
+
+// IdempotencyKeyHeader is the header holding the idempotency key of a request.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// WithIdempotencyKey adds an idempotency key to the context (a random key is generated if key is empty).
+// Kitty clients send it with requests, so that all retries of a call (e.g. by the backoff middleware) use the same key,
+// and are only processed once by servers using the idempotency package.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	if key == "" {
+		key = generateRequestID()
+	}
+	return context.WithValue(ctx, idempotencyKey, key)
+}
+
+// IdempotencyKey returns the idempotency key of the context (an empty string if none is defined).
+func IdempotencyKey(ctx context.Context) string {
+	key, _ := ctx.Value(idempotencyKey).(string)
+	return key
+}
+
+// forwardIdempotencyKey sets the idempotency key of the context to an outgoing request.
+func forwardIdempotencyKey(ctx context.Context, r *http.Request) context.Context {
+	if key := IdempotencyKey(ctx); key != "" {
+		r.Header.Set(IdempotencyKeyHeader, key)
+	}
+	return ctx
+}
@@ -0,0 +1,282 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/objenious/kitty"
+)
+
+// ErrKeyReused is returned to requests reusing an idempotency key with a different body.
+var ErrKeyReused = errors.New("the idempotency key has been used with a different request body")
+
+// storeTimeout is the timeout of the Finish & Cancel store calls, that do not use the request context,
+// so that they are not canceled with the request.
+const storeTimeout = 5 * time.Second
+
+// ScopeFunc returns the scope of the idempotency keys of a request (e.g. the authenticated user),
+// so that a client can not replay the responses of another client.
+type ScopeFunc func(r *http.Request) string
+
+// ByAuthorization scopes idempotency keys by the Authorization header of requests.
+func ByAuthorization(r *http.Request) string {
+	return r.Header.Get("Authorization")
+}
+
+// config holds the configuration of the idempotency middleware.
+type config struct {
+	store           Store
+	methods         map[string]bool
+	scope           ScopeFunc
+	lockTTL         time.Duration
+	logger          log.Logger
+	maxBodySize     int64
+	maxResponseSize int
+	errorEncoder    kithttp.ErrorEncoder
+}
+
+// Option is an option for the idempotency middleware.
+type Option func(*config) *config
+
+// WithStore defines the response store (default: a MemoryStore keeping responses for 24 hours).
+func WithStore(s Store) Option {
+	return func(c *config) *config {
+		c.store = s
+		return c
+	}
+}
+
+// Methods defines the HTTP methods of requests handled by the middleware (default: POST & PATCH).
+func Methods(methods ...string) Option {
+	return func(c *config) *config {
+		c.methods = map[string]bool{}
+		for _, m := range methods {
+			c.methods[m] = true
+		}
+		return c
+	}
+}
+
+// Scope defines the scope of idempotency keys (default: ByAuthorization).
+func Scope(fn ScopeFunc) Option {
+	return func(c *config) *config {
+		c.scope = fn
+		return c
+	}
+}
+
+// LockTTL defines how long a key is reserved by a request being processed (default: 1 minute).
+// It should be longer than the processing time of requests: if the reservation can not be released
+// (e.g. if the service stops), the key can be used again after this delay.
+func LockTTL(d time.Duration) Option {
+	return func(c *config) *config {
+		c.lockTTL = d
+		return c
+	}
+}
+
+// Logger defines the logger used to log store errors (default: the logger of the context, see kitty.Logger).
+func Logger(l log.Logger) Option {
+	return func(c *config) *config {
+		c.logger = l
+		return c
+	}
+}
+
+// MaxBodySize defines the maximum size of request bodies, that are read to detect reused keys (default: 1MiB).
+// Larger requests get a 413 error.
+func MaxBodySize(n int64) Option {
+	return func(c *config) *config {
+		c.maxBodySize = n
+		return c
+	}
+}
+
+// MaxResponseSize defines the maximum size of stored response bodies (default: 1MiB).
+// Larger responses are not stored: the key is released, and can be used again.
+func MaxResponseSize(n int) Option {
+	return func(c *config) *config {
+		c.maxResponseSize = n
+		return c
+	}
+}
+
+// ErrorEncoder defines the encoder of the errors returned by the middleware (default: kitty.EncodeError).
+func ErrorEncoder(enc kithttp.ErrorEncoder) Option {
+	return func(c *config) *config {
+		c.errorEncoder = enc
+		return c
+	}
+}
+
+// Middleware creates a HTTP middleware, processing requests with the same idempotency key
+// (see kitty.IdempotencyKeyHeader) only once.
+// The first response (status, headers and body) is stored, and returned to subsequent requests with the same key,
+// scope, method and path. Requests sent while a request with the same key is in progress get a 409 error,
+// and requests reusing a key with a different body get a 422 error.
+// 429 & 5XX responses are not stored, so that requests can be retried, nor are responses exceeding MaxResponseSize.
+//
+//	t.HTTPMiddlewares(idempotency.Middleware())
+func Middleware(opts ...Option) func(http.Handler) http.Handler {
+	cfg := &config{
+		store:           NewMemoryStore(24 * time.Hour),
+		methods:         map[string]bool{http.MethodPost: true, http.MethodPatch: true},
+		scope:           ByAuthorization,
+		lockTTL:         time.Minute,
+		maxBodySize:     1 << 20,
+		maxResponseSize: 1 << 20,
+		errorEncoder:    kitty.EncodeError,
+	}
+	for _, opt := range opts {
+		cfg = opt(cfg)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(kitty.IdempotencyKeyHeader)
+			if key == "" || !cfg.methods[r.Method] {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx := r.Context()
+			body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, cfg.maxBodySize))
+			switch {
+			case err != nil && int64(len(body)) >= cfg.maxBodySize:
+				cfg.errorEncoder(ctx, kitty.WithStatus(err, http.StatusRequestEntityTooLarge), w)
+				return
+			case err != nil:
+				cfg.errorEncoder(ctx, kitty.BadRequest(err), w)
+				return
+			}
+			_ = r.Body.Close()
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			bodyHash := hash(body)
+
+			key = r.Method + " " + r.URL.Path + " " + hash([]byte(cfg.scope(r))) + " " + key
+			stored, err := cfg.store.Start(ctx, key, cfg.lockTTL)
+			switch {
+			case err == ErrInProgress:
+				cfg.errorEncoder(ctx, kitty.Conflict(err), w)
+				return
+			case err != nil:
+				cfg.log(ctx, "start", err)
+				cfg.errorEncoder(ctx, kitty.Unavailable(err), w)
+				return
+			case stored != nil && stored.BodyHash != bodyHash:
+				cfg.errorEncoder(ctx, kitty.WithStatus(ErrKeyReused, http.StatusUnprocessableEntity), w)
+				return
+			case stored != nil:
+				replay(w, stored)
+				return
+			}
+
+			rec := &recorder{ResponseWriter: w, status: http.StatusOK, limit: cfg.maxResponseSize}
+			defer func() {
+				// the reservation is released if the handler panics
+				if p := recover(); p != nil {
+					cfg.cancel(ctx, key)
+					panic(p)
+				}
+			}()
+			next.ServeHTTP(rec, r)
+			if rec.header == nil {
+				rec.header = w.Header().Clone()
+			}
+			// retryable errors are not stored, so that the request can be retried
+			// large responses are not stored either
+			if rec.status == http.StatusTooManyRequests || rec.status >= 500 || rec.overflow {
+				cfg.cancel(ctx, key)
+				return
+			}
+			sctx, cancel := context.WithTimeout(detached{ctx}, storeTimeout)
+			defer cancel()
+			resp := &Response{Status: rec.status, Header: rec.header, Body: rec.body.Bytes(), BodyHash: bodyHash}
+			if err := cfg.store.Finish(sctx, key, resp); err != nil {
+				cfg.log(ctx, "finish", err)
+			}
+		})
+	}
+}
+
+// cancel releases the reservation of a key.
+func (cfg *config) cancel(ctx context.Context, key string) {
+	sctx, cancel := context.WithTimeout(detached{ctx}, storeTimeout)
+	defer cancel()
+	if err := cfg.store.Cancel(sctx, key); err != nil {
+		cfg.log(ctx, "cancel", err)
+	}
+}
+
+// log logs a store error.
+func (cfg *config) log(ctx context.Context, op string, err error) {
+	l := cfg.logger
+	if l == nil {
+		l = kitty.Logger(ctx)
+	}
+	_ = l.Log("msg", "idempotency store failed", "operation", op, "error", err)
+}
+
+// hash returns the hex encoded SHA-256 hash of b.
+func hash(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+// detached is a context that is never canceled, but keeps the values of its parent.
+type detached struct {
+	parent context.Context
+}
+
+func (detached) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detached) Done() <-chan struct{}               { return nil }
+func (detached) Err() error                          { return nil }
+func (d detached) Value(key interface{}) interface{} { return d.parent.Value(key) }
+
+// replay writes a stored response.
+func replay(w http.ResponseWriter, resp *Response) {
+	for k, v := range resp.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(resp.Status)
+	_, _ = w.Write(resp.Body)
+}
+
+// recorder records the response written by a handler, and stops recording the body if it exceeds limit bytes.
+type recorder struct {
+	http.ResponseWriter
+	status   int
+	header   http.Header
+	body     bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.header == nil {
+		r.status = status
+		r.header = r.ResponseWriter.Header().Clone()
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if r.header == nil {
+		r.WriteHeader(http.StatusOK)
+	}
+	if !r.overflow {
+		if r.body.Len()+len(b) > r.limit {
+			r.overflow = true
+			r.body = bytes.Buffer{}
+		} else {
+			r.body.Write(b)
+		}
+	}
+	return r.ResponseWriter.Write(b)
+}
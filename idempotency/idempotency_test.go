@@ -0,0 +1,219 @@
+package idempotency
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/objenious/kitty"
+)
+
+func TestMiddleware(t *testing.T) {
+	var calls int32
+	status := http.StatusCreated
+	h := Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		w.Header().Set("X-Call", strconv.Itoa(int(n)))
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("call " + strconv.Itoa(int(n))))
+	}))
+	do := func(method, path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if key != "" {
+			req.Header.Set(kitty.IdempotencyKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	first := do("POST", "/foo", "a")
+	replayed := do("POST", "/foo", "a")
+	if replayed.Code != http.StatusCreated || replayed.Body.String() != "call 1" || replayed.Header().Get("X-Call") != "1" {
+		t.Errorf("response should be replayed, got %d %q", replayed.Code, replayed.Body.String())
+	}
+	if first.Body.String() != "call 1" || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("handler should be called once, got %d calls", calls)
+	}
+	if w := do("POST", "/bar", "a"); w.Body.String() != "call 2" {
+		t.Errorf("keys should be scoped by path, got %q", w.Body.String())
+	}
+	if w := do("PUT", "/foo", "a"); w.Body.String() != "call 3" {
+		t.Errorf("PUT requests should not be handled, got %q", w.Body.String())
+	}
+	if w := do("POST", "/foo", ""); w.Body.String() != "call 4" {
+		t.Errorf("requests without key should not be handled, got %q", w.Body.String())
+	}
+
+	status = http.StatusServiceUnavailable
+	do("POST", "/foo", "b")
+	status = http.StatusOK
+	if w := do("POST", "/foo", "b"); w.Code != http.StatusOK || w.Body.String() != "call 6" {
+		t.Errorf("retryable errors should not be stored, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestMiddlewareInProgress(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	h := Middleware(WithStore(NewMemoryStore(time.Minute)), Methods("PUT"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	req := func() *http.Request {
+		req := httptest.NewRequest("PUT", "/foo", nil)
+		req.Header.Set(kitty.IdempotencyKeyHeader, "a")
+		return req
+	}
+	done := make(chan struct{})
+	go func() {
+		h.ServeHTTP(httptest.NewRecorder(), req())
+		close(done)
+	}()
+	<-started
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req())
+	if w.Code != http.StatusConflict {
+		t.Errorf("concurrent duplicates should get a 409 error, got %d", w.Code)
+	}
+	close(release)
+	<-done
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req())
+	if w.Code != http.StatusOK {
+		t.Errorf("response should be replayed, got %d", w.Code)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.TODO()
+	s := NewMemoryStore(50 * time.Millisecond)
+	if resp, err := s.Start(ctx, "a", time.Minute); resp != nil || err != nil {
+		t.Fatalf("Start should reserve the key, got %v %v", resp, err)
+	}
+	if _, err := s.Start(ctx, "a", time.Minute); err != ErrInProgress {
+		t.Errorf("Start should return ErrInProgress, got %v", err)
+	}
+	_ = s.Cancel(ctx, "a")
+	if _, err := s.Start(ctx, "a", time.Minute); err != nil {
+		t.Errorf("Start should reserve a canceled key, got %v", err)
+	}
+	_ = s.Finish(ctx, "a", &Response{Status: http.StatusOK})
+	if resp, err := s.Start(ctx, "a", time.Minute); err != nil || resp == nil || resp.Status != http.StatusOK {
+		t.Errorf("Start should return the stored response, got %v %v", resp, err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if resp, err := s.Start(ctx, "a", time.Minute); resp != nil || err != nil {
+		t.Errorf("expired responses should be removed, got %v %v", resp, err)
+	}
+}
+
+func TestMemoryStoreLockTTL(t *testing.T) {
+	ctx := context.TODO()
+	s := NewMemoryStore(time.Minute)
+	_, _ = s.Start(ctx, "a", 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if _, err := s.Start(ctx, "a", time.Minute); err != nil {
+		t.Errorf("expired reservations should be released, got %v", err)
+	}
+}
+
+func TestMiddlewareScope(t *testing.T) {
+	var calls int32
+	h := Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		b, _ := ioutil.ReadAll(r.Body)
+		_, _ = w.Write(b)
+	}))
+	do := func(auth, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/foo", strings.NewReader(body))
+		req.Header.Set(kitty.IdempotencyKeyHeader, "a")
+		req.Header.Set("Authorization", auth)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+	if w := do("alice", "foo"); w.Body.String() != "foo" {
+		t.Errorf("the request body should be readable by the handler, got %q", w.Body.String())
+	}
+	if w := do("bob", "bar"); w.Body.String() != "bar" || atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("clients should not replay the responses of other clients, got %q", w.Body.String())
+	}
+	if w := do("alice", "bar"); w.Code != http.StatusUnprocessableEntity || atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("reusing a key with a different body should return a 422 error, got %d", w.Code)
+	}
+	if w := do("alice", "foo"); w.Body.String() != "foo" || atomic.LoadInt32(&calls) != 2 {
+		t.Errorf("the response should be replayed, got %q", w.Body.String())
+	}
+}
+
+// ctxStore is a store failing when the context is done.
+type ctxStore struct {
+	*MemoryStore
+}
+
+func (s ctxStore) Finish(ctx context.Context, key string, resp *Response) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.MemoryStore.Finish(ctx, key, resp)
+}
+
+func TestMiddlewareCanceledRequest(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	h := Middleware(WithStore(ctxStore{NewMemoryStore(time.Minute)}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the client disconnects
+		cancel()
+	}))
+	req := httptest.NewRequest("POST", "/foo", nil).WithContext(ctx)
+	req.Header.Set(kitty.IdempotencyKeyHeader, "a")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest("POST", "/foo", nil)
+	req.Header.Set(kitty.IdempotencyKeyHeader, "a")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("the response should be stored even if the request is canceled, got %d", w.Code)
+	}
+}
+
+func TestMiddlewareLimits(t *testing.T) {
+	var calls int32
+	h := Middleware(MaxBodySize(4), MaxResponseSize(7), ErrorEncoder(kithttp.DefaultErrorEncoder))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		body, _ := ioutil.ReadAll(r.Body)
+		_, _ = w.Write(body)
+		_, _ = w.Write([]byte(" call " + strconv.Itoa(int(n))))
+	}))
+	do := func(body, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/foo", strings.NewReader(body))
+		req.Header.Set(kitty.IdempotencyKeyHeader, key)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("large", "a"); w.Code != http.StatusRequestEntityTooLarge || atomic.LoadInt32(&calls) != 0 {
+		t.Errorf("a large request should get a 413 error, got %d", w.Code)
+	}
+	if w := do("foo", "b"); w.Body.String() != "foo call 1" {
+		t.Errorf("invalid response %q", w.Body.String())
+	}
+	if w := do("foo", "b"); w.Body.String() != "foo call 2" {
+		t.Errorf("a large response should not be stored, got %q", w.Body.String())
+	}
+	if w := do("", "c"); w.Body.String() != " call 3" {
+		t.Errorf("invalid response %q", w.Body.String())
+	}
+	if w := do("", "c"); w.Body.String() != " call 3" {
+		t.Errorf("a small response should be stored, got %q", w.Body.String())
+	}
+}
@@ -0,0 +1,100 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Response is a stored response.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+	// BodyHash is the hash of the body of the request, used to detect keys reused with a different body.
+	BodyHash string
+}
+
+// ErrInProgress is returned by Store.Start when a request with the same key is being processed.
+var ErrInProgress = errors.New("a request with the same idempotency key is in progress")
+
+// Store stores the responses of requests, by idempotency key.
+type Store interface {
+	// Start reserves a key for a new request, for at most lockTTL. If a response was stored for the key, it is returned.
+	// If the key is reserved by a request being processed, ErrInProgress is returned.
+	Start(ctx context.Context, key string, lockTTL time.Duration) (*Response, error)
+	// Finish stores the response of a request, and releases the key reservation.
+	Finish(ctx context.Context, key string, resp *Response) error
+	// Cancel releases the key reservation, without storing a response (so that the request can be retried).
+	Cancel(ctx context.Context, key string) error
+}
+
+// entry is an entry of the memory store. A nil response means that the request is in progress.
+type entry struct {
+	resp    *Response
+	expires time.Time
+}
+
+// MemoryStore is an in-memory Store. It is only suitable for services running a single instance.
+type MemoryStore struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]*entry
+	lastSweep time.Time
+}
+
+var _ Store = &MemoryStore{}
+
+// NewMemoryStore creates an in-memory store, keeping responses for ttl.
+// Reservations of keys by requests being processed expire after the lock TTL given to Start.
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{ttl: ttl, entries: map[string]*entry{}, lastSweep: time.Now()}
+}
+
+// Start implements Store.
+func (s *MemoryStore) Start(_ context.Context, key string, lockTTL time.Duration) (*Response, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now)
+	if e, ok := s.entries[key]; ok && now.Before(e.expires) {
+		if e.resp == nil {
+			return nil, ErrInProgress
+		}
+		return e.resp, nil
+	}
+	s.entries[key] = &entry{expires: now.Add(lockTTL)}
+	return nil, nil
+}
+
+// Finish implements Store.
+func (s *MemoryStore) Finish(_ context.Context, key string, resp *Response) error {
+	s.mu.Lock()
+	s.entries[key] = &entry{resp: resp, expires: time.Now().Add(s.ttl)}
+	s.mu.Unlock()
+	return nil
+}
+
+// Cancel implements Store.
+func (s *MemoryStore) Cancel(_ context.Context, key string) error {
+	s.mu.Lock()
+	delete(s.entries, key)
+	s.mu.Unlock()
+	return nil
+}
+
+// sweep removes expired entries, at most once per ttl.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < s.ttl {
+		return
+	}
+	for k, e := range s.entries {
+		if now.After(e.expires) {
+			delete(s.entries, k)
+		}
+	}
+	s.lastSweep = now
+}
@@ -0,0 +1,42 @@
+package kitty
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	kithttp "github.com/go-kit/kit/transport/http"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	var keys []string
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(IdempotencyKeyHeader))
+	}))
+	defer downstream.Close()
+	u, _ := url.Parse(downstream.URL)
+	client := NewClient("POST", u, kithttp.EncodeJSONRequest, func(context.Context, *http.Response) (interface{}, error) {
+		return nil, nil
+	}).Endpoint()
+
+	if _, err := client(context.TODO(), nil); err != nil {
+		t.Fatalf("client: %s", err)
+	}
+	ctx := WithIdempotencyKey(context.TODO(), "")
+	if IdempotencyKey(ctx) == "" {
+		t.Error("WithIdempotencyKey should generate a key")
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := client(ctx, nil); err != nil {
+			t.Fatalf("client: %s", err)
+		}
+	}
+	if _, err := client(WithIdempotencyKey(context.TODO(), "abcd"), nil); err != nil {
+		t.Fatalf("client: %s", err)
+	}
+	if len(keys) != 4 || keys[0] != "" || keys[1] != IdempotencyKey(ctx) || keys[2] != keys[1] || keys[3] != "abcd" {
+		t.Errorf("invalid forwarded keys: %v", keys)
+	}
+}
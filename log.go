@@ -21,7 +21,8 @@ func (s *Server) Logger(l log.Logger) *Server {
 // LogContext defines the list of keys to add to all log lines.
 // Keys may vary depending on transport.
 // Available keys for the http transport are : http-method, http-uri, http-path, http-proto, http-requesthost,
-// http-remote-addr, http-x-forwarded-for, http-x-forwarded-proto, http-user-agent and http-x-request-id.
+// http-remote-addr, http-x-forwarded-for, http-x-forwarded-proto, http-user-agent, http-x-request-id,
+// http-tls-client-subject and http-route (and trace-id and span-id, when using a tracing middleware).
 func (s *Server) LogContext(keys ...string) *Server {
 	s.logkeys = keys
 	return s
@@ -49,13 +50,16 @@ func (s *Server) addLoggerToContextMiddleware(m endpoint.Middleware, t Transport
 }
 
 // Logger will return the logger that has been injected into the context by the kitty
-// server. This function can only be called from an endpoint.
+// server. If it is called outside of an endpoint, a logger doing nothing is returned.
 func Logger(ctx context.Context) log.Logger {
-	return ctx.Value(logKey).(log.Logger)
+	if l, ok := ctx.Value(logKey).(log.Logger); ok {
+		return l
+	}
+	return &nopLogger{}
 }
 
 // LogMessage will log a message.
-// This function can only be called from an endpoint.
+// Messages are only logged if this function is called from an endpoint.
 func LogMessage(ctx context.Context, msg string, keyvals ...interface{}) error {
 	l := Logger(ctx)
 	keyvals = append(keyvals, "msg", msg)
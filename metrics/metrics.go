@@ -0,0 +1,158 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
+	"github.com/objenious/kitty"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefaultPath is the default path of the metrics handler.
+const DefaultPath = "/metrics"
+
+type config struct {
+	namespace string
+	subsystem string
+	buckets   []float64
+	registry  *prometheus.Registry
+}
+
+// Option is a metrics option.
+type Option func(*config) *config
+
+// Namespace defines the namespace of metrics (default: "kitty").
+func Namespace(ns string) Option {
+	return func(c *config) *config {
+		c.namespace = ns
+		return c
+	}
+}
+
+// Subsystem defines the subsystem of metrics (default: none).
+func Subsystem(s string) Option {
+	return func(c *config) *config {
+		c.subsystem = s
+		return c
+	}
+}
+
+// Buckets defines the buckets of the duration histogram, in seconds (default: prometheus.DefBuckets).
+func Buckets(b []float64) Option {
+	return func(c *config) *config {
+		c.buckets = b
+		return c
+	}
+}
+
+// Registry defines the registry metrics are registered to, and served from (default: the prometheus default registry).
+func Registry(r *prometheus.Registry) Option {
+	return func(c *config) *config {
+		c.registry = r
+		return c
+	}
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{
+		namespace: "kitty",
+		buckets:   prometheus.DefBuckets,
+	}
+	for _, opt := range opts {
+		c = opt(c)
+	}
+	return c
+}
+
+func (c *config) registerer() prometheus.Registerer {
+	if c.registry != nil {
+		return c.registry
+	}
+	return prometheus.DefaultRegisterer
+}
+
+func (c *config) gatherer() prometheus.Gatherer {
+	if c.registry != nil {
+		return c.registry
+	}
+	return prometheus.DefaultGatherer
+}
+
+// NewMiddleware creates a middleware recording endpoint calls:
+//
+// * requests_total: number of requests, labelled by route method & path,
+//
+// * errors_total: number of errors, labelled by route method & path, status code and retryable class,
+//
+// * request_duration_seconds: request duration histogram, labelled by route method & path.
+//
+// The route path template is used (see kitty.Route), not the request URI, so that label cardinality remains low.
+func NewMiddleware(opts ...Option) endpoint.Middleware {
+	c := newConfig(opts)
+	reg := c.registerer()
+	requests := kitprometheus.NewCounter(register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: c.namespace,
+		Subsystem: c.subsystem,
+		Name:      "requests_total",
+		Help:      "Number of requests.",
+	}, []string{"method", "path"})).(*prometheus.CounterVec))
+	errors := kitprometheus.NewCounter(register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: c.namespace,
+		Subsystem: c.subsystem,
+		Name:      "errors_total",
+		Help:      "Number of errors.",
+	}, []string{"method", "path", "code", "retryable"})).(*prometheus.CounterVec))
+	duration := kitprometheus.NewHistogram(register(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: c.namespace,
+		Subsystem: c.subsystem,
+		Name:      "request_duration_seconds",
+		Help:      "Request duration in seconds.",
+		Buckets:   c.buckets,
+	}, []string{"method", "path"})).(*prometheus.HistogramVec))
+
+	return func(e endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (response interface{}, err error) {
+			method, path := kitty.Route(ctx)
+			start := time.Now()
+			response, err = e(ctx, request)
+			duration.With("method", method, "path", path).Observe(time.Since(start).Seconds())
+			requests.With("method", method, "path", path).Add(1)
+			if err != nil {
+				code := kitty.ErrorStatusCode(err)
+				errors.With("method", method, "path", path, "code", strconv.Itoa(code), "retryable", strconv.FormatBool(kitty.IsRetryable(err))).Add(1)
+			}
+			return
+		}
+	}
+}
+
+// register registers a collector, or returns the existing collector if it was already registered.
+func register(reg prometheus.Registerer, c prometheus.Collector) prometheus.Collector {
+	if err := reg.Register(c); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector
+		}
+		panic(err)
+	}
+	return c
+}
+
+// Handler returns a handler serving metrics in the Prometheus text exposition format.
+func Handler(opts ...Option) http.Handler {
+	c := newConfig(opts)
+	return promhttp.HandlerFor(c.gatherer(), promhttp.HandlerOpts{})
+}
+
+// Expose registers the metrics handler to the admin server of t (see kitty.HTTPTransport.AdminHandler).
+// If path is empty, DefaultPath is used.
+func Expose(t *kitty.HTTPTransport, path string, opts ...Option) *kitty.HTTPTransport {
+	if path == "" {
+		path = DefaultPath
+	}
+	return t.AdminHandler("GET", path, Handler(opts...))
+}
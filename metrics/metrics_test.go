@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/objenious/kitty"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	tr := kitty.NewHTTPTransport(kitty.DefaultConfig).
+		Endpoint("GET", "/ok", func(context.Context, interface{}) (interface{}, error) {
+			return "ok", nil
+		}).
+		Endpoint("GET", "/fail", func(context.Context, interface{}) (interface{}, error) {
+			return nil, kitty.Retryable(errors.New("fail"))
+		})
+	tr = Expose(tr, "/custom-metrics", Registry(reg))
+	if err := tr.RegisterEndpoints(NewMiddleware(Registry(reg), Namespace("test"))); err != nil {
+		t.Fatalf("RegisterEndpoints returned an error : %s", err)
+	}
+	// creating the middleware twice must not fail
+	NewMiddleware(Registry(reg), Namespace("test"))
+
+	for _, path := range []string{"/ok", "/ok", "/fail"} {
+		tr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	rec := httptest.NewRecorder()
+	tr.ServeHTTP(rec, httptest.NewRequest("GET", "/custom-metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("the metrics handler returned a %d status", rec.Code)
+	}
+	body, _ := ioutil.ReadAll(rec.Body)
+	for _, expected := range []string{
+		`test_requests_total{method="GET",path="/ok"} 2`,
+		`test_requests_total{method="GET",path="/fail"} 1`,
+		`test_errors_total{code="500",method="GET",path="/fail",retryable="true"} 1`,
+		`test_request_duration_seconds_count{method="GET",path="/ok"} 2`,
+	} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("metrics should contain `%s`, got:\n%s", expected, body)
+		}
+	}
+}
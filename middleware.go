@@ -7,7 +7,6 @@ import (
 	"time"
 
 	"github.com/go-kit/kit/endpoint"
-	kithttp "github.com/go-kit/kit/transport/http"
 )
 
 // nopMiddleware is the default middleware, and does nothing.
@@ -57,10 +56,7 @@ func LogEndpoint(fields ...LogOption) endpoint.Middleware {
 			response, err = e(ctx, request)
 			code := http.StatusOK
 			if err != nil {
-				code = http.StatusInternalServerError
-				if sc, ok := err.(kithttp.StatusCoder); ok {
-					code = sc.StatusCode()
-				}
+				code = ErrorStatusCode(err)
 			}
 			switch {
 			case opts[LogResponse]:
@@ -74,3 +70,44 @@ func LogEndpoint(fields ...LogOption) endpoint.Middleware {
 		}
 	}
 }
+
+// TimeoutEndpoint creates a middleware that bounds the execution time of endpoints.
+// When the timeout expires, the context of the endpoint is canceled, and a retryable error with a 504 status code
+// is returned, without waiting for the endpoint (its response is discarded).
+// If the endpoint panics before the timeout, the panic is propagated to the caller.
+func TimeoutEndpoint(d time.Duration) endpoint.Middleware {
+	return func(e endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			type result struct {
+				response interface{}
+				err      error
+				panic    interface{}
+			}
+			res := make(chan result, 1)
+			go func() {
+				// a panic in this goroutine would crash the process: it is recovered, and raised again by the caller
+				defer func() {
+					if p := recover(); p != nil {
+						res <- result{panic: p}
+					}
+				}()
+				response, err := e(ctx, request)
+				res <- result{response: response, err: err}
+			}()
+			select {
+			case r := <-res:
+				if r.panic != nil {
+					panic(r.panic)
+				}
+				return r.response, r.err
+			case <-ctx.Done():
+				if ctx.Err() == context.DeadlineExceeded {
+					return nil, errDeadlineExceeded
+				}
+				return nil, ctx.Err()
+			}
+		}
+	}
+}
@@ -6,8 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-kit/kit/log"
 )
@@ -70,3 +73,49 @@ func TestLogEndpoint(t *testing.T) {
 		}
 	}
 }
+
+func TestTimeoutEndpoint(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	tr := NewHTTPTransport(DefaultConfig).
+		Endpoint("GET", "/slow", func(context.Context, interface{}) (interface{}, error) {
+			// ignores its context
+			<-release
+			return "late", nil
+		}, Timeout(20*time.Millisecond)).
+		Endpoint("GET", "/fast", func(context.Context, interface{}) (interface{}, error) {
+			return "OK", nil
+		}, Timeout(time.Second))
+	_ = tr.RegisterEndpoints(nopMiddleware)
+
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	tr.ServeHTTP(rec, httptest.NewRequest("GET", "/slow", nil))
+	if rec.Code != http.StatusGatewayTimeout || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("a slow endpoint should return a 504 status once the timeout expires, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	tr.ServeHTTP(rec, httptest.NewRequest("GET", "/fast", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("a fast endpoint should return a 200 status, got %d", rec.Code)
+	}
+
+	_, err := TimeoutEndpoint(10*time.Millisecond)(func(ctx context.Context, _ interface{}) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})(context.TODO(), nil)
+	if !IsRetryable(err) || ErrorStatusCode(err) != http.StatusGatewayTimeout || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("TimeoutEndpoint should return a retryable 504 error, got %v", err)
+	}
+
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Errorf("a panic of the endpoint should be propagated to the caller, got %v", p)
+			}
+		}()
+		_, _ = TimeoutEndpoint(time.Second)(func(context.Context, interface{}) (interface{}, error) {
+			panic("boom")
+		})(context.TODO(), nil)
+	}()
+}
@@ -0,0 +1,192 @@
+package kitty
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	kithttp "github.com/go-kit/kit/transport/http"
+)
+
+// ProblemContentType is the content type of RFC 7807 problem details.
+const ProblemContentType = "application/problem+json"
+
+// Problem is a RFC 7807 problem details error.
+// Extension members (e.g. "retryable") are stored in Extensions.
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]interface{}
+	// Response holds the response the problem was parsed from (see HTTPError), and is not part of the problem details.
+	Response *ResponseError
+}
+
+var _ error = &Problem{}
+var _ Retryabler = &Problem{}
+var _ kithttp.StatusCoder = &Problem{}
+var _ kithttp.Headerer = &Problem{}
+
+// ProblemFielder defines an error adding extension members to its problem details.
+type ProblemFielder interface {
+	ProblemFields() map[string]interface{}
+}
+
+// problem members, that can not be used as extension members.
+var problemMembers = map[string]bool{"type": true, "title": true, "status": true, "detail": true, "instance": true}
+
+// Error implements error.
+func (p *Problem) Error() string {
+	switch {
+	case p.Detail != "":
+		return p.Detail
+	case p.Title != "":
+		return p.Title
+	default:
+		return httpError(p.Status).Error()
+	}
+}
+
+// StatusCode implements kithttp.StatusCoder.
+func (p *Problem) StatusCode() int {
+	return p.Status
+}
+
+// Headers implements kithttp.Headerer.
+// Only the relevant headers are returned (Retry-After & WWW-Authenticate).
+func (p *Problem) Headers() http.Header {
+	if p.Response == nil {
+		return http.Header{}
+	}
+	return p.Response.Headers()
+}
+
+// Retryable implements Retryabler.
+// If the problem has no "retryable" extension member, 429 & 5XX errors are retryable.
+func (p *Problem) Retryable() bool {
+	if retryable, ok := p.Extensions["retryable"].(bool); ok {
+		return retryable
+	}
+	return httpError(p.Status).Retryable()
+}
+
+// Cause implements Retryabler.
+func (p *Problem) Cause() error {
+	return errors.New(p.Error())
+}
+
+// Unwrap returns the response error (see errors.As), if the problem was parsed from a response.
+func (p *Problem) Unwrap() error {
+	if p.Response == nil {
+		return nil
+	}
+	return p.Response
+}
+
+// MarshalJSON implements json.Marshaler.
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		if !problemMembers[k] {
+			m[k] = v
+		}
+	}
+	m["type"] = p.Type
+	if p.Type == "" {
+		m["type"] = "about:blank"
+	}
+	m["status"] = p.Status
+	if p.Title != "" {
+		m["title"] = p.Title
+	}
+	if p.Detail != "" {
+		m["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		m["instance"] = p.Instance
+	}
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (p *Problem) UnmarshalJSON(b []byte) error {
+	m := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	members := map[string]interface{}{"type": &p.Type, "title": &p.Title, "status": &p.Status, "detail": &p.Detail, "instance": &p.Instance}
+	for k, raw := range m {
+		if member, ok := members[k]; ok {
+			if err := json.Unmarshal(raw, member); err != nil {
+				return err
+			}
+			continue
+		}
+		var v interface{}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return err
+		}
+		if p.Extensions == nil {
+			p.Extensions = map[string]interface{}{}
+		}
+		p.Extensions[k] = v
+	}
+	return nil
+}
+
+// NewProblem builds the problem details of an error.
+// The status code is provided by ErrorStatusCode, the "retryable" extension member by the first Retryabler
+// of the error chain (if any), and additional extension members by ProblemFielder.
+func NewProblem(err error) *Problem {
+	if p, ok := err.(*Problem); ok {
+		return p
+	}
+	code := ErrorStatusCode(err)
+	p := &Problem{
+		Title:      httpError(code).Error(),
+		Status:     code,
+		Detail:     err.Error(),
+		Extensions: map[string]interface{}{},
+	}
+	for e := err; e != nil; e = unwrap(e) {
+		if f, ok := e.(ProblemFielder); ok {
+			for k, v := range f.ProblemFields() {
+				p.Extensions[k] = v
+			}
+			break
+		}
+	}
+	// the retryable extension member is only defined by Retryabler errors,
+	// so that clients fall back to the status code for other errors
+	for e := err; e != nil; e = unwrap(e) {
+		if r, ok := e.(Retryabler); ok {
+			p.Extensions["retryable"] = r.Retryable()
+			break
+		}
+	}
+	return p
+}
+
+// EncodeProblem is a go-kit error encoder, writing errors as RFC 7807 problem details (see NewProblem).
+// Headers are provided by ErrorHeaders (e.g. Retry-After for 429 & 503 errors).
+// Problems without a valid status code are written as 500 errors.
+// It can be used for all endpoints of a transport (see Config.EncodeError), or for a specific endpoint (see ErrorEncoder).
+func EncodeProblem(_ context.Context, err error, w http.ResponseWriter) {
+	p := NewProblem(err)
+	if p.Status < 100 {
+		cp := *p
+		cp.Status = http.StatusInternalServerError
+		p = &cp
+	}
+	for k, values := range ErrorHeaders(err) {
+		for _, v := range values {
+			w.Header().Add(k, v)
+		}
+	}
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,132 @@
+package kitty
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	kithttp "github.com/go-kit/kit/transport/http"
+)
+
+type quotaError struct{}
+
+func (quotaError) Error() string   { return "quota exceeded" }
+func (quotaError) StatusCode() int { return http.StatusTooManyRequests }
+func (quotaError) Headers() http.Header {
+	return http.Header{"Retry-After": []string{"10"}}
+}
+func (quotaError) ProblemFields() map[string]interface{} {
+	return map[string]interface{}{"quota": "foo"}
+}
+
+func TestEncodeProblem(t *testing.T) {
+	tr := NewHTTPTransport(Config{EncodeError: EncodeProblem}).
+		Endpoint("GET", "/quota", func(context.Context, interface{}) (interface{}, error) {
+			return nil, quotaError{}
+		}).
+		Endpoint("GET", "/plain", func(context.Context, interface{}) (interface{}, error) {
+			return nil, Retryable(errors.New("failure"))
+		}, ErrorEncoder(kithttp.DefaultErrorEncoder))
+	_ = tr.RegisterEndpoints(nopMiddleware)
+	ts := httptest.NewServer(tr)
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL + "/quota")
+	_, err := NewClient("GET", u, kithttp.EncodeJSONRequest, decodeTestResponse).Endpoint()(context.TODO(), nil)
+	p, ok := err.(*Problem)
+	if !ok {
+		t.Fatalf("the client should return a *Problem, got %T", err)
+	}
+	if p.Status != http.StatusTooManyRequests || p.Title != "Too Many Requests" || p.Detail != "quota exceeded" || p.Type != "about:blank" {
+		t.Errorf("invalid problem %+v", p)
+	}
+	// quotaError does not implement Retryabler, so the status code defines if the problem is retryable
+	if _, ok := p.Extensions["retryable"]; p.Extensions["quota"] != "foo" || ok || !IsRetryable(p) {
+		t.Errorf("invalid problem extensions %+v", p.Extensions)
+	}
+	var rerr *ResponseError
+	if !errors.As(err, &rerr) || rerr.Status != http.StatusTooManyRequests || rerr.Header.Get("Retry-After") != "10" || len(rerr.Body) == 0 {
+		t.Errorf("the response error should be available with errors.As, got %+v", rerr)
+	}
+
+	resp, err := http.Get(ts.URL + "/quota")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ra := resp.Header.Get("Retry-After"); ra != "10" {
+		t.Errorf("the Retry-After header should be 10, got %s", ra)
+	}
+	if _, ok := HTTPErrorWithLimit(resp, 10).(*ResponseError); !ok {
+		t.Error("HTTPErrorWithLimit should not parse problems exceeding the limit")
+	}
+	if b, _ := ioutil.ReadAll(resp.Body); len(b) == 0 {
+		t.Error("the response body should still be readable")
+	}
+
+	resp, err = http.Get(ts.URL + "/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct == ProblemContentType {
+		t.Error("the endpoint error encoder should override the transport error encoder")
+	}
+	if _, ok := HTTPError(resp).(*ResponseError); !ok {
+		t.Error("HTTPError should not return a *Problem for a plain body")
+	}
+}
+
+func TestProblemRetryable(t *testing.T) {
+	tcs := []struct {
+		problem   Problem
+		retryable bool
+	}{
+		{problem: Problem{Status: http.StatusServiceUnavailable}, retryable: true},
+		{problem: Problem{Status: http.StatusBadRequest}, retryable: false},
+		{problem: Problem{Status: http.StatusServiceUnavailable, Extensions: map[string]interface{}{"retryable": false}}, retryable: false},
+		{problem: Problem{Status: http.StatusBadRequest, Extensions: map[string]interface{}{"retryable": true}}, retryable: true},
+	}
+	for _, tc := range tcs {
+		if IsRetryable(&tc.problem) != tc.retryable {
+			t.Errorf("IsRetryable(%+v) should return %t", tc.problem, tc.retryable)
+		}
+	}
+}
+
+func TestNewProblemRetryable(t *testing.T) {
+	tcs := []struct {
+		err       error
+		extension interface{}
+		retryable bool
+	}{
+		{err: errors.New("failure"), extension: nil, retryable: true},
+		{err: quotaError{}, extension: nil, retryable: true},
+		{err: Retryable(BadRequest(errors.New("failure"))), extension: true, retryable: true},
+		{err: Permanent(Unavailable(errors.New("failure"))), extension: false, retryable: false},
+		{err: NotFound(errors.New("failure")), extension: false, retryable: false},
+	}
+	for _, tc := range tcs {
+		p := NewProblem(tc.err)
+		if p.Extensions["retryable"] != tc.extension {
+			t.Errorf("NewProblem(%v): the retryable extension member should be %v, got %v", tc.err, tc.extension, p.Extensions["retryable"])
+		}
+		if IsRetryable(p) != tc.retryable {
+			t.Errorf("NewProblem(%v) should be retryable: %t", tc.err, tc.retryable)
+		}
+	}
+}
+
+func TestEncodeProblemWithoutStatus(t *testing.T) {
+	p := &Problem{Title: "failure"}
+	w := httptest.NewRecorder()
+	EncodeProblem(context.TODO(), p, w)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("a problem without status should be written as a 500 error, got %d", w.Code)
+	}
+	if p.Status != 0 {
+		t.Error("the problem should not be modified")
+	}
+}
@@ -0,0 +1,66 @@
+package queue
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/endpoint"
+)
+
+// DecodeRequestFunc extracts a user-domain request object from a queue message.
+type DecodeRequestFunc func(context.Context, Message) (interface{}, error)
+
+// queueendpoint encapsulates everything required to build
+// an endpoint consuming messages from a Subscriber.
+type queueendpoint struct {
+	name        string
+	subscriber  Subscriber
+	endpoint    endpoint.Endpoint
+	handler     endpoint.Endpoint
+	decoder     DecodeRequestFunc
+	concurrency int
+}
+
+// EndpointOption is an option for a queue endpoint.
+type EndpointOption func(*queueendpoint) *queueendpoint
+
+// Endpoint registers an endpoint, that will be called for each message delivered by sub.
+// name identifies the subscription in logs.
+// Unless specified, NopDecoder will decode the message, and messages will be processed one at a time.
+func (t *Transport) Endpoint(name string, sub Subscriber, ep endpoint.Endpoint, opts ...EndpointOption) *Transport {
+	e := &queueendpoint{
+		name:        name,
+		subscriber:  sub,
+		endpoint:    ep,
+		decoder:     NopDecoder,
+		concurrency: 1,
+	}
+	for _, opt := range opts {
+		e = opt(e)
+	}
+	t.endpoints = append(t.endpoints, e)
+	return t
+}
+
+// Decoder defines the message decoder for an endpoint.
+// If none is provided, NopDecoder is used.
+func Decoder(dec DecodeRequestFunc) EndpointOption {
+	return func(e *queueendpoint) *queueendpoint {
+		e.decoder = dec
+		return e
+	}
+}
+
+// Concurrency defines the maximum number of messages processed in parallel by an endpoint (default: 1).
+func Concurrency(n int) EndpointOption {
+	return func(e *queueendpoint) *queueendpoint {
+		if n > 0 {
+			e.concurrency = n
+		}
+		return e
+	}
+}
+
+// NopDecoder is a decoder that passes the message as is to the endpoint.
+func NopDecoder(_ context.Context, msg Message) (interface{}, error) {
+	return msg, nil
+}
@@ -0,0 +1,132 @@
+package queue
+
+import (
+	"context"
+	"strconv"
+	"sync"
+)
+
+// MemorySubscriber is an in-memory Subscriber, mostly useful for tests.
+// Rejected messages are delivered again, dead-lettered messages are kept and can be inspected.
+type MemorySubscriber struct {
+	mu           sync.Mutex
+	next         int
+	pending      []*memoryMessage
+	acked        []Message
+	deadLettered []Message
+	signal       chan struct{}
+}
+
+var _ Subscriber = &MemorySubscriber{}
+
+// NewMemorySubscriber creates an in-memory subscriber.
+func NewMemorySubscriber() *MemorySubscriber {
+	return &MemorySubscriber{signal: make(chan struct{}, 1)}
+}
+
+// Publish adds a message to the queue, and returns its id.
+func (s *MemorySubscriber) Publish(data []byte, attributes map[string]string) string {
+	s.mu.Lock()
+	s.next++
+	msg := &memoryMessage{sub: s, id: strconv.Itoa(s.next), data: data, attributes: attributes}
+	s.mu.Unlock()
+	s.push(msg)
+	return msg.id
+}
+
+// Receive implements Subscriber.
+func (s *MemorySubscriber) Receive(ctx context.Context) (Message, error) {
+	for {
+		s.mu.Lock()
+		if len(s.pending) > 0 {
+			msg := s.pending[0]
+			s.pending = s.pending[1:]
+			msg.deliveries++
+			if len(s.pending) > 0 {
+				s.notify()
+			}
+			s.mu.Unlock()
+			return msg, nil
+		}
+		s.mu.Unlock()
+		select {
+		case <-s.signal:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Acked returns the list of acknowledged messages.
+func (s *MemorySubscriber) Acked() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message{}, s.acked...)
+}
+
+// DeadLettered returns the list of dead-lettered messages.
+func (s *MemorySubscriber) DeadLettered() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message{}, s.deadLettered...)
+}
+
+// Len returns the number of messages waiting to be delivered.
+func (s *MemorySubscriber) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
+
+func (s *MemorySubscriber) push(msg *memoryMessage) {
+	s.mu.Lock()
+	s.pending = append(s.pending, msg)
+	s.notify()
+	s.mu.Unlock()
+}
+
+// notify wakes up a waiting receiver.
+func (s *MemorySubscriber) notify() {
+	select {
+	case s.signal <- struct{}{}:
+	default:
+	}
+}
+
+type memoryMessage struct {
+	sub        *MemorySubscriber
+	id         string
+	data       []byte
+	attributes map[string]string
+	deliveries int
+}
+
+func (m *memoryMessage) ID() string                    { return m.id }
+func (m *memoryMessage) Data() []byte                  { return m.data }
+func (m *memoryMessage) Attributes() map[string]string { return m.attributes }
+
+// Deliveries returns the number of times the message has been delivered.
+func (m *memoryMessage) Deliveries() int {
+	m.sub.mu.Lock()
+	defer m.sub.mu.Unlock()
+	return m.deliveries
+}
+
+func (m *memoryMessage) Ack() error {
+	m.sub.mu.Lock()
+	m.sub.acked = append(m.sub.acked, m)
+	m.sub.mu.Unlock()
+	return nil
+}
+
+func (m *memoryMessage) Nack() error {
+	m.sub.push(m)
+	return nil
+}
+
+func (m *memoryMessage) DeadLetter() error {
+	m.sub.mu.Lock()
+	m.sub.deadLettered = append(m.sub.deadLettered, m)
+	m.sub.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,26 @@
+package queue
+
+import "context"
+
+// Message is a message delivered by a Subscriber.
+type Message interface {
+	// ID returns the message id.
+	ID() string
+	// Data returns the message payload.
+	Data() []byte
+	// Attributes returns the message attributes.
+	Attributes() map[string]string
+	// Ack acknowledges the message, it will not be delivered again.
+	Ack() error
+	// Nack rejects the message, it will be delivered again.
+	Nack() error
+	// DeadLetter rejects the message permanently (e.g. by sending it to a dead-letter queue).
+	// Subscribers without dead-letter support should acknowledge the message.
+	DeadLetter() error
+}
+
+// Subscriber is the interface message queue implementations must implement.
+type Subscriber interface {
+	// Receive blocks until a message is available, or ctx is done.
+	Receive(ctx context.Context) (Message, error)
+}
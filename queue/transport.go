@@ -0,0 +1,179 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/objenious/kitty"
+)
+
+// Transport defines a message queue consumer transport for a kitty Server.
+// Messages are acknowledged when the endpoint succeeds, rejected and delivered again when
+// the endpoint returns a retryable error (see kitty.IsRetryable), and dead-lettered otherwise.
+type Transport struct {
+	endpoints []*queueendpoint
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+var _ kitty.Transport = &Transport{}
+
+// NewTransport creates a new message queue consumer transport.
+func NewTransport() *Transport {
+	return &Transport{
+		stop: make(chan struct{}),
+	}
+}
+
+// RegisterEndpoints registers all configured endpoints, wraps them with the m middleware.
+func (t *Transport) RegisterEndpoints(m endpoint.Middleware) error {
+	for _, ep := range t.endpoints {
+		ep.handler = m(ep.endpoint)
+	}
+	return nil
+}
+
+type contextKey int
+
+const (
+	contextKeySubscription contextKey = iota
+	contextKeyMessageID
+)
+
+var queueLogkeys = map[string]interface{}{
+	"queue-subscription": contextKeySubscription,
+	"queue-message-id":   contextKeyMessageID,
+}
+
+// LogKeys returns the list of name key to context key mappings.
+// Available keys are : queue-subscription and queue-message-id.
+func (t *Transport) LogKeys() map[string]interface{} {
+	return queueLogkeys
+}
+
+// Start starts pulling messages from all subscribers.
+// It returns when the transport is shut down, or when a subscriber fails.
+func (t *Transport) Start(ctx context.Context) error {
+	t.mu.Lock()
+	select {
+	case <-t.stop:
+		t.mu.Unlock()
+		return nil
+	default:
+	}
+	t.done = make(chan struct{})
+	defer close(t.done)
+	t.mu.Unlock()
+
+	rctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-t.stop:
+			cancel()
+		case <-rctx.Done():
+		}
+	}()
+
+	// messages being processed must not be canceled by the shutdown.
+	hctx := detachedContext{ctx}
+	errs := make(chan error, 1)
+	var wg sync.WaitGroup
+	for _, ep := range t.endpoints {
+		_ = kitty.LogMessage(ctx, fmt.Sprintf("Consuming messages from: %s", ep.name))
+		for i := 0; i < ep.concurrency; i++ {
+			wg.Add(1)
+			go func(ep *queueendpoint) {
+				defer wg.Done()
+				if err := t.consume(rctx, hctx, ep); err != nil {
+					select {
+					case errs <- err:
+					default:
+					}
+					cancel()
+				}
+			}(ep)
+		}
+	}
+	wg.Wait()
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return nil
+	}
+}
+
+// consume pulls messages from a subscriber until rctx is canceled.
+func (t *Transport) consume(rctx, hctx context.Context, ep *queueendpoint) error {
+	for {
+		msg, err := ep.subscriber.Receive(rctx)
+		if rctx.Err() != nil {
+			if msg != nil {
+				_ = msg.Nack()
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		t.handle(hctx, ep, msg)
+	}
+}
+
+// handle processes a message, and acks, nacks or dead-letters it depending on the endpoint result.
+func (t *Transport) handle(ctx context.Context, ep *queueendpoint, msg Message) {
+	ctx = context.WithValue(ctx, contextKeySubscription, ep.name)
+	ctx = context.WithValue(ctx, contextKeyMessageID, msg.ID())
+	request, err := ep.decoder(ctx, msg)
+	if err == nil {
+		_, err = ep.handler(ctx, request)
+	}
+	switch {
+	case err == nil:
+		err = msg.Ack()
+	case kitty.IsRetryable(err):
+		err = msg.Nack()
+	default:
+		err = msg.DeadLetter()
+	}
+	if err != nil {
+		_ = kitty.LogMessage(ctx, "unable to acknowledge message", "subscription", ep.name, "id", msg.ID(), "error", err)
+	}
+}
+
+// Shutdown stops pulling messages, and waits for messages being processed.
+func (t *Transport) Shutdown(ctx context.Context) error {
+	t.mu.Lock()
+	select {
+	case <-t.stop:
+	default:
+		close(t.stop)
+	}
+	done := t.done
+	t.mu.Unlock()
+	if done == nil {
+		return nil
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// detachedContext keeps the values of its parent, but is never canceled.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
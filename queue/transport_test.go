@@ -0,0 +1,126 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/objenious/kitty"
+)
+
+func TestTransport(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	sub := NewMemorySubscriber()
+	var mu sync.Mutex
+	calls := map[string]int{}
+	ep := func(_ context.Context, req interface{}) (interface{}, error) {
+		data := req.(string)
+		mu.Lock()
+		calls[data]++
+		n := calls[data]
+		mu.Unlock()
+		switch {
+		case data == "retryable" && n == 1:
+			return nil, kitty.Retryable(errors.New("temporary error"))
+		case data == "fatal":
+			return nil, errors.New("fatal error")
+		}
+		return nil, nil
+	}
+	tr := NewTransport().Endpoint("test", sub, ep, Decoder(decodeString), Concurrency(2))
+	exitError := make(chan error)
+	go func() {
+		exitError <- kitty.NewServer(tr).Run(ctx)
+	}()
+
+	sub.Publish([]byte("ok"), nil)
+	sub.Publish([]byte("retryable"), nil)
+	sub.Publish([]byte("fatal"), nil)
+
+	start := time.Now()
+	for len(sub.Acked())+len(sub.DeadLettered()) < 3 {
+		if time.Since(start) > time.Second {
+			t.Fatal("messages were not processed within 1sec")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := len(sub.Acked()); n != 2 {
+		t.Errorf("2 messages should have been acked, not %d", n)
+	}
+	if dl := sub.DeadLettered(); len(dl) != 1 || string(dl[0].Data()) != "fatal" {
+		t.Errorf("the message with a non retryable error should have been dead-lettered, got %+v", dl)
+	}
+	mu.Lock()
+	if calls["retryable"] != 2 {
+		t.Errorf("the message with a retryable error should have been delivered twice, not %d", calls["retryable"])
+	}
+	mu.Unlock()
+
+	cancel()
+	select {
+	case <-time.After(time.Second):
+		t.Error("Server.Run has not stopped after 1sec")
+	case err := <-exitError:
+		if err != nil && err != context.Canceled {
+			t.Errorf("Server.Run returned an error : %s", err)
+		}
+	}
+}
+
+func TestShutdownDrain(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	sub := NewMemorySubscriber()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	ep := func(ctx context.Context, _ interface{}) (interface{}, error) {
+		close(started)
+		<-release
+		return nil, ctx.Err()
+	}
+	tr := NewTransport().Endpoint("test", sub, ep)
+	go func() {
+		_ = kitty.NewServer(tr).Run(ctx)
+	}()
+
+	sub.Publish([]byte("foo"), nil)
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("the message was not processed within 1sec")
+	}
+	shutdown := make(chan error)
+	go func() {
+		shutdown <- tr.Shutdown(context.TODO())
+	}()
+	select {
+	case <-shutdown:
+		t.Error("Shutdown should wait for messages being processed")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case err := <-shutdown:
+		if err != nil {
+			t.Errorf("Shutdown returned an error : %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown has not returned after 1sec")
+	}
+	if n := len(sub.Acked()); n != 1 {
+		t.Errorf("the message being processed during shutdown should have been acked, %d acked messages", n)
+	}
+
+	sub.Publish([]byte("bar"), nil)
+	time.Sleep(50 * time.Millisecond)
+	if sub.Len() != 1 {
+		t.Error("no message should be pulled after shutdown")
+	}
+}
+
+func decodeString(_ context.Context, msg Message) (interface{}, error) {
+	return string(msg.Data()), nil
+}
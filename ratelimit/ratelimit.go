@@ -0,0 +1,245 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/go-kit/kit/endpoint"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/objenious/kitty"
+)
+
+// Limit is the limit of a token bucket: Burst requests are allowed at once, and Rate requests per second after that.
+// Burst should be at least 1. A Limit with a Rate <= 0 does not limit requests.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// PerSecond allows n requests per second, with bursts of n requests.
+func PerSecond(n int) Limit {
+	return Limit{Rate: float64(n), Burst: n}
+}
+
+// PerMinute allows n requests per minute, with bursts of n requests.
+func PerMinute(n int) Limit {
+	return Limit{Rate: float64(n) / 60, Burst: n}
+}
+
+// ErrLimited is the cause of the errors returned to rate limited requests.
+var ErrLimited = errors.New("rate limit exceeded")
+
+// KeyFunc returns the client key of a request (e.g. an IP address or an API key). A token bucket is used per client and route.
+type KeyFunc func(ctx context.Context) string
+
+// ByIP returns the IP address of the client, i.e. the remote address of the request.
+// X-Forwarded-For is ignored, as it is set by clients: use ByForwardedIP behind a proxy.
+func ByIP(ctx context.Context) string {
+	addr, _ := ctx.Value(kithttp.ContextKeyRequestRemoteAddr).(string)
+	return hostIP(addr)
+}
+
+// ByForwardedIP returns a KeyFunc using the IP address of the client behind trusted proxies
+// (given as IP addresses or CIDR ranges, e.g. "10.0.0.0/8").
+// If the remote address of a request is a trusted proxy, the X-Forwarded-For addresses are read from right to left,
+// and the first address that is not a trusted proxy is used. Addresses added by clients are never used.
+func ByForwardedIP(trusted ...string) (KeyFunc, error) {
+	nets := make([]*net.IPNet, 0, len(trusted))
+	for _, t := range trusted {
+		if !strings.Contains(t, "/") {
+			if ip := net.ParseIP(t); ip != nil && ip.To4() != nil {
+				t += "/32"
+			} else {
+				t += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(t)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	isTrusted := func(addr string) bool {
+		ip := net.ParseIP(addr)
+		for _, n := range nets {
+			if ip != nil && n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	return func(ctx context.Context) string {
+		client := ByIP(ctx)
+		if !isTrusted(client) {
+			return client
+		}
+		fwd, _ := ctx.Value(kithttp.ContextKeyRequestXForwardedFor).(string)
+		hops := strings.Split(fwd, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := hostIP(strings.TrimSpace(hops[i]))
+			if hop == "" {
+				break
+			}
+			client = hop
+			if !isTrusted(hop) {
+				break
+			}
+		}
+		return client
+	}, nil
+}
+
+// hostIP removes the port of an address, if any.
+func hostIP(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// ByContext returns the string value of a context key (e.g. an API key added to the context by a ServerBefore function).
+func ByContext(key interface{}) KeyFunc {
+	return func(ctx context.Context) string {
+		val, _ := ctx.Value(key).(string)
+		return val
+	}
+}
+
+// ByHeader returns the value of a request header (e.g. X-Api-Key).
+// It can only be used with the HTTP middleware (see Limiter.HTTPMiddleware): with the endpoint middleware,
+// it returns an empty key, and all clients share the same buckets. Use ByContext for the endpoint middleware.
+func ByHeader(name string) KeyFunc {
+	return func(ctx context.Context) string {
+		if r, ok := ctx.Value(requestKey).(*http.Request); ok {
+			return r.Header.Get(name)
+		}
+		return ""
+	}
+}
+
+type contextKey int
+
+const requestKey contextKey = iota
+
+// route is a method and path.
+type route struct {
+	method, path string
+}
+
+// defaultRoute is the route of the requests of the HTTP middleware not matching any route defined by Limiter.Route.
+var defaultRoute = route{method: "*", path: "*"}
+
+// Limiter limits requests per client and route, using token buckets.
+type Limiter struct {
+	limit        Limit
+	key          KeyFunc
+	store        Store
+	routes       map[route]Limit
+	errorEncoder kithttp.ErrorEncoder
+}
+
+// NewLimiter creates a rate limiter, applying limit to each client (identified by key) on each route.
+// Buckets are stored in memory (see NewMemoryStore).
+func NewLimiter(limit Limit, key KeyFunc) *Limiter {
+	return &Limiter{limit: limit, key: key, store: NewMemoryStore(), routes: map[route]Limit{}, errorEncoder: kitty.EncodeError}
+}
+
+// Store defines the store of the token buckets (default: a MemoryStore).
+func (l *Limiter) Store(s Store) *Limiter {
+	l.store = s
+	return l
+}
+
+// Route defines a specific limit for a route, given as the path template of the endpoint (e.g. "/foo/{id}", see kitty.Route).
+func (l *Limiter) Route(method, path string, limit Limit) *Limiter {
+	l.routes[route{method: method, path: path}] = limit
+	return l
+}
+
+// ErrorEncoder defines the encoder of the errors returned by the HTTP middleware (default: kitty.EncodeError).
+func (l *Limiter) ErrorEncoder(enc kithttp.ErrorEncoder) *Limiter {
+	l.errorEncoder = enc
+	return l
+}
+
+// Middleware creates a rate limiting endpoint middleware, using the route of the endpoint (see kitty.Route).
+// Rate limited requests get a retryable 429 error, with a Retry-After header, so that kitty clients back off.
+// If the store fails, requests are not limited. ByHeader can not be used to identify clients (see ByHeader).
+func (l *Limiter) Middleware() endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			method, path := kitty.Route(ctx)
+			if err := l.take(ctx, method, path); err != nil {
+				return nil, err
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// HTTPMiddleware creates a rate limiting HTTP middleware, that can be used with kitty.HTTPTransport.HTTPMiddlewares.
+// As requests are not routed yet, requests matching a route defined by Route use the limit of this route,
+// and all other requests of a client share a single bucket.
+// Rate limited requests get a 429 error, with a Retry-After header.
+func (l *Limiter) HTTPMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := kithttp.PopulateRequestContext(r.Context(), r)
+			ctx = context.WithValue(ctx, requestKey, r)
+			rt := l.match(r.Method, r.URL.Path)
+			if err := l.take(ctx, rt.method, rt.path); err != nil {
+				l.errorEncoder(ctx, err, w)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// match returns the route matching a request, or defaultRoute.
+// Template variables (e.g. {id}) match a single path segment.
+func (l *Limiter) match(method, path string) route {
+	segments := strings.Split(path, "/")
+	for rt := range l.routes {
+		if rt.method == method && matchTemplate(strings.Split(rt.path, "/"), segments) {
+			return rt
+		}
+	}
+	return defaultRoute
+}
+
+// matchTemplate checks if the segments of a path match the segments of a path template.
+func matchTemplate(template, segments []string) bool {
+	if len(template) != len(segments) {
+		return false
+	}
+	for i, t := range template {
+		if t != segments[i] && !(strings.HasPrefix(t, "{") && strings.HasSuffix(t, "}") && segments[i] != "") {
+			return false
+		}
+	}
+	return true
+}
+
+// take takes a token from the bucket of the client and route, and returns an error if the request is limited.
+func (l *Limiter) take(ctx context.Context, method, path string) error {
+	limit, ok := l.routes[route{method: method, path: path}]
+	if !ok {
+		limit = l.limit
+	}
+	if limit.Rate <= 0 {
+		return nil
+	}
+	allowed, wait, err := l.store.Take(ctx, method+" "+path+" "+l.key(ctx), limit)
+	if err != nil {
+		_ = kitty.LogMessage(ctx, "rate limit store failed", "error", err)
+		return nil
+	}
+	if !allowed {
+		return kitty.WithRetryAfter(kitty.TooManyRequests(ErrLimited), wait)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/objenious/kitty"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.TODO()
+	s := NewMemoryStore()
+	limit := Limit{Rate: 10, Burst: 2}
+	for i := 0; i < 2; i++ {
+		if ok, _, _ := s.Take(ctx, "a", limit); !ok {
+			t.Fatalf("burst requests should be allowed")
+		}
+	}
+	ok, wait, _ := s.Take(ctx, "a", limit)
+	if ok || wait <= 0 || wait > 100*time.Millisecond {
+		t.Errorf("request should be limited for at most 100ms, got %v %v", ok, wait)
+	}
+	if ok, _, _ := s.Take(ctx, "b", limit); !ok {
+		t.Errorf("keys should have their own bucket")
+	}
+	time.Sleep(wait)
+	if ok, _, _ := s.Take(ctx, "a", limit); !ok {
+		t.Errorf("bucket should be refilled")
+	}
+
+	s.IdleTimeout(10 * time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	// sweep all shards
+	for i := 0; i < 10*shardCount; i++ {
+		_, _, _ = s.Take(ctx, strconv.Itoa(i), limit)
+	}
+	for _, sh := range s.shards {
+		if _, ok := sh.buckets["a"]; ok {
+			t.Errorf("idle buckets should be removed")
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	l := NewLimiter(PerMinute(1), ByContext(kithttp.ContextKeyRequestAuthorization)).Route("GET", "/unlimited", Limit{})
+	e := l.Middleware()(func(context.Context, interface{}) (interface{}, error) { return "ok", nil })
+	tr := kitty.NewHTTPTransport(kitty.DefaultConfig).
+		Endpoint("GET", "/foo", e).
+		Endpoint("GET", "/bar", e).
+		Endpoint("GET", "/unlimited", e)
+	_ = tr.RegisterEndpoints(func(e endpoint.Endpoint) endpoint.Endpoint { return e })
+
+	tcs := []struct {
+		path, key string
+		code      int
+	}{
+		{path: "/foo", key: "a", code: http.StatusOK},
+		{path: "/foo", key: "a", code: http.StatusTooManyRequests},
+		{path: "/foo", key: "b", code: http.StatusOK},
+		{path: "/bar", key: "a", code: http.StatusOK},
+		{path: "/unlimited", key: "a", code: http.StatusOK},
+		{path: "/unlimited", key: "a", code: http.StatusOK},
+	}
+	for _, tc := range tcs {
+		req := httptest.NewRequest("GET", tc.path, nil)
+		req.Header.Set("Authorization", tc.key)
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, req)
+		if w.Code != tc.code {
+			t.Errorf("%s %s: expected %d, got %d", tc.path, tc.key, tc.code, w.Code)
+		}
+		if tc.code == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "60" {
+			t.Errorf("expected a Retry-After header of 60s, got %q", w.Header().Get("Retry-After"))
+		}
+	}
+
+	_, _ = e(context.TODO(), nil)
+	_, err := e(context.TODO(), nil)
+	if !kitty.IsRetryable(err) || !errors.Is(err, ErrLimited) {
+		t.Errorf("rate limited requests should get a retryable error, got %v", err)
+	}
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	h := NewLimiter(PerSecond(1), ByHeader("X-Api-Key")).
+		Route("POST", "/foo/{id}", PerSecond(2)).
+		ErrorEncoder(func(_ context.Context, err error, w http.ResponseWriter) {
+			w.WriteHeader(kitty.ErrorStatusCode(err))
+			_, _ = w.Write([]byte("limited"))
+		}).
+		HTTPMiddleware()(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	tcs := []struct {
+		key  string
+		path string
+		code int
+	}{
+		{key: "a", path: "/bar", code: http.StatusOK},
+		{key: "a", path: "/baz", code: http.StatusTooManyRequests},
+		{key: "b", path: "/bar", code: http.StatusOK},
+		{key: "a", path: "/foo/1", code: http.StatusOK},
+		{key: "a", path: "/foo/2", code: http.StatusOK},
+		{key: "a", path: "/foo/3", code: http.StatusTooManyRequests},
+	}
+	for _, tc := range tcs {
+		req := httptest.NewRequest("POST", tc.path, nil)
+		req.Header.Set("X-Api-Key", tc.key)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != tc.code {
+			t.Errorf("%s %s: expected %d, got %d", tc.key, tc.path, tc.code, w.Code)
+		}
+		if w.Code == http.StatusTooManyRequests && w.Body.String() != "limited" {
+			t.Errorf("the error encoder should be used, got %q", w.Body.String())
+		}
+	}
+}
+
+func TestByIP(t *testing.T) {
+	ctx := context.WithValue(context.TODO(), kithttp.ContextKeyRequestRemoteAddr, "10.0.0.1:1234")
+	if ip := ByIP(ctx); ip != "10.0.0.1" {
+		t.Errorf("expected remote address, got %q", ip)
+	}
+	ctx = context.WithValue(ctx, kithttp.ContextKeyRequestXForwardedFor, "192.168.0.1, 10.0.0.2")
+	if ip := ByIP(ctx); ip != "10.0.0.1" {
+		t.Errorf("X-Forwarded-For should be ignored, got %q", ip)
+	}
+}
+
+func TestByForwardedIP(t *testing.T) {
+	if _, err := ByForwardedIP("foo"); err == nil {
+		t.Error("invalid proxies should be rejected")
+	}
+	key, err := ByForwardedIP("10.0.0.0/8", "192.168.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcs := []struct {
+		remote, fwd, expected string
+	}{
+		{remote: "1.2.3.4:1234", fwd: "5.6.7.8", expected: "1.2.3.4"},
+		{remote: "10.0.0.1:1234", fwd: "", expected: "10.0.0.1"},
+		{remote: "10.0.0.1:1234", fwd: "5.6.7.8", expected: "5.6.7.8"},
+		{remote: "10.0.0.1:1234", fwd: "5.6.7.8, 192.168.0.1, 10.0.0.2", expected: "5.6.7.8"},
+		{remote: "10.0.0.1:1234", fwd: "spoofed, 5.6.7.8", expected: "5.6.7.8"},
+	}
+	for _, tc := range tcs {
+		ctx := context.WithValue(context.TODO(), kithttp.ContextKeyRequestRemoteAddr, tc.remote)
+		ctx = context.WithValue(ctx, kithttp.ContextKeyRequestXForwardedFor, tc.fwd)
+		if ip := key(ctx); ip != tc.expected {
+			t.Errorf("%s/%s: expected %q, got %q", tc.remote, tc.fwd, tc.expected, ip)
+		}
+	}
+}
+
+func TestSpoofedForwardedFor(t *testing.T) {
+	h := NewLimiter(PerSecond(1), ByIP).HTTPMiddleware()(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	for i, expected := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		req := httptest.NewRequest("GET", "/foo", nil)
+		req.Header.Set("X-Forwarded-For", strconv.Itoa(i))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != expected {
+			t.Errorf("request %d: expected %d, got %d", i, expected, w.Code)
+		}
+	}
+}
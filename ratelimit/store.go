@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+)
+
+// Store holds the token buckets of a limiter.
+type Store interface {
+	// Take takes a token from the bucket of key. If no token is available, false is returned,
+	// with the delay until a token will be available.
+	Take(ctx context.Context, key string, limit Limit) (ok bool, wait time.Duration, err error)
+}
+
+// bucket is a token bucket.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// shard is a part of the buckets of a MemoryStore, with its own lock.
+type shard struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// shardCount is the number of shards of a MemoryStore.
+const shardCount = 32
+
+// MemoryStore is an in-memory Store. Buckets are sharded by key, to limit lock contention.
+// Limits are enforced per instance of the service.
+type MemoryStore struct {
+	idleTimeout time.Duration
+	shards      [shardCount]*shard
+}
+
+var _ Store = &MemoryStore{}
+
+// NewMemoryStore creates an in-memory store. Buckets are removed after being idle for 10 minutes.
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{idleTimeout: 10 * time.Minute}
+	now := time.Now()
+	for i := range s.shards {
+		s.shards[i] = &shard{buckets: map[string]*bucket{}, lastSweep: now}
+	}
+	return s
+}
+
+// IdleTimeout defines after how long an unused bucket is removed (default: 10 minutes).
+// It should be longer than the time needed to refill a bucket (Burst / Rate seconds),
+// otherwise clients may get more requests than allowed.
+func (s *MemoryStore) IdleTimeout(d time.Duration) *MemoryStore {
+	s.idleTimeout = d
+	return s
+}
+
+// Take implements Store.
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (bool, time.Duration, error) {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	sh := s.shards[h.Sum32()%shardCount]
+
+	now := time.Now()
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if now.Sub(sh.lastSweep) > s.idleTimeout {
+		for k, b := range sh.buckets {
+			if now.Sub(b.last) > s.idleTimeout {
+				delete(sh.buckets, k)
+			}
+		}
+		sh.lastSweep = now
+	}
+	b, ok := sh.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		sh.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+	return false, time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second)), nil
+}
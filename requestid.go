@@ -0,0 +1,106 @@
+package kitty
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	kithttp "github.com/go-kit/kit/transport/http"
+)
+
+// DefaultRequestIDHeader is the default request id header.
+const DefaultRequestIDHeader = "X-Request-Id"
+
+// requestID is the configuration of the RequestID middleware.
+type requestID struct {
+	header   string
+	generate func() string
+}
+
+// RequestIDOption is an option for the RequestID middleware.
+type RequestIDOption func(*requestID) *requestID
+
+// RequestIDHeader defines the name of the request id header (default: X-Request-Id).
+func RequestIDHeader(name string) RequestIDOption {
+	return func(r *requestID) *requestID {
+		r.header = name
+		return r
+	}
+}
+
+// RequestIDGenerator defines the function generating request ids (default: 16 random bytes, hex encoded).
+func RequestIDGenerator(fn func() string) RequestIDOption {
+	return func(r *requestID) *requestID {
+		r.generate = fn
+		return r
+	}
+}
+
+// RequestID creates a HTTP middleware that ensures that every request has a request id.
+// If the request has no request id header, an id is generated.
+// The id is set in the response headers, is available in endpoints with RequestIDFromContext,
+// can be logged with the http-x-request-id log key, and is forwarded by kitty.Client.
+//
+//	t.HTTPMiddlewares(kitty.RequestID(kitty.RequestIDHeader("X-Correlation-Id")))
+func RequestID(opts ...RequestIDOption) func(http.Handler) http.Handler {
+	cfg := &requestID{header: DefaultRequestIDHeader, generate: generateRequestID}
+	for _, opt := range opts {
+		cfg = opt(cfg)
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(cfg.header)
+			if id == "" {
+				id = cfg.generate()
+				r.Header.Set(cfg.header, id)
+			}
+			w.Header().Set(cfg.header, id)
+			ctx := context.WithValue(r.Context(), requestIDKey, id)
+			ctx = context.WithValue(ctx, requestIDHeaderKey, cfg.header)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// generateRequestID generates a random request id.
+func generateRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// populateRequestIDContext copies the request id set by the RequestID middleware to the go-kit request id context key,
+// so that it can be logged even if a custom header is used.
+func populateRequestIDContext(ctx context.Context, _ *http.Request) context.Context {
+	if id, ok := ctx.Value(requestIDKey).(string); ok {
+		return context.WithValue(ctx, kithttp.ContextKeyRequestXRequestID, id)
+	}
+	return ctx
+}
+
+// RequestIDFromContext returns the id of the current request (an empty string if none is available).
+func RequestIDFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDKey).(string); ok {
+		return id
+	}
+	id, _ := ctx.Value(kithttp.ContextKeyRequestXRequestID).(string)
+	return id
+}
+
+// forwardRequestID sets the request id of the current request to an outgoing request,
+// unless the outgoing request already has one.
+func forwardRequestID(ctx context.Context, r *http.Request) context.Context {
+	id := RequestIDFromContext(ctx)
+	if id == "" {
+		return ctx
+	}
+	header, ok := ctx.Value(requestIDHeaderKey).(string)
+	if !ok {
+		header = DefaultRequestIDHeader
+	}
+	if r.Header.Get(header) == "" {
+		r.Header.Set(header, id)
+	}
+	return ctx
+}
@@ -0,0 +1,59 @@
+package kitty
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	kithttp "github.com/go-kit/kit/transport/http"
+)
+
+func TestRequestID(t *testing.T) {
+	var forwarded string
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header.Get("X-Correlation-Id")
+	}))
+	defer downstream.Close()
+	u, _ := url.Parse(downstream.URL)
+	client := NewClient("GET", u, kithttp.EncodeJSONRequest, func(context.Context, *http.Response) (interface{}, error) {
+		return nil, nil
+	}).Endpoint()
+
+	var fromCtx, logged string
+	tr := NewHTTPTransport(DefaultConfig).
+		HTTPMiddlewares(RequestID(RequestIDHeader("X-Correlation-Id"), RequestIDGenerator(func() string { return "generated" }))).
+		Endpoint("GET", "/foo", func(ctx context.Context, _ interface{}) (interface{}, error) {
+			fromCtx = RequestIDFromContext(ctx)
+			logged, _ = ctx.Value(kithttp.ContextKeyRequestXRequestID).(string)
+			return client(ctx, nil)
+		})
+	_ = tr.RegisterEndpoints(nopMiddleware)
+
+	tcs := []struct {
+		header   string
+		expected string
+	}{
+		{header: "", expected: "generated"},
+		{header: "abcd", expected: "abcd"},
+	}
+	for _, tc := range tcs {
+		forwarded = ""
+		req := httptest.NewRequest("GET", "/foo", nil)
+		if tc.header != "" {
+			req.Header.Set("X-Correlation-Id", tc.header)
+		}
+		rec := httptest.NewRecorder()
+		tr.ServeHTTP(rec, req)
+		if id := rec.Header().Get("X-Correlation-Id"); id != tc.expected {
+			t.Errorf("the response request id should be %s, got %s", tc.expected, id)
+		}
+		if fromCtx != tc.expected || logged != tc.expected {
+			t.Errorf("the context request id should be %s, got %s/%s", tc.expected, fromCtx, logged)
+		}
+		if forwarded != tc.expected {
+			t.Errorf("the forwarded request id should be %s, got %s", tc.expected, forwarded)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/go-kit/kit/endpoint"
 	"github.com/go-kit/kit/log"
@@ -16,6 +17,9 @@ type Server struct {
 	middleware endpoint.Middleware
 	shutdown   []func()
 
+	preStopDelay    time.Duration
+	shutdownTimeout time.Duration
+
 	logkeys []string
 	logger  log.Logger
 
@@ -27,14 +31,28 @@ type contextKey int
 const (
 	// context key for logger
 	logKey contextKey = iota
+	// context key for the subject of the client certificate
+	tlsClientSubjectKey
+	// context keys for the method and path template of the route
+	routeMethodKey
+	routePathKey
+	// context keys for the request id and request id header, set by the RequestID middleware
+	requestIDKey
+	requestIDHeaderKey
+	// context key for the idempotency key of outgoing requests
+	idempotencyKey
+	// context keys for the trace and span ids of the current span, set by tracing middlewares
+	traceIDKey
+	spanIDKey
 )
 
 // NewServer creates a kitty server.
 func NewServer(t ...Transport) *Server {
 	return &Server{
-		transports: t,
-		logger:     &nopLogger{},
-		middleware: nopMiddleware,
+		transports:      t,
+		logger:          &nopLogger{},
+		middleware:      nopMiddleware,
+		shutdownTimeout: DefaultShutdownTimeout,
 	}
 }
 
@@ -42,7 +60,7 @@ func NewServer(t ...Transport) *Server {
 func (s *Server) Run(ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
-	stop := make(chan error)
+	stop := make(chan error, len(s.transports)+1)
 	exit := make(chan error)
 	ctx = s.addLoggerToContext(ctx, nil)
 	for _, t := range s.transports {
@@ -65,8 +83,10 @@ func (s *Server) Run(ctx context.Context) error {
 		for _, fn := range s.shutdown {
 			fn()
 		}
+		sctx, scancel := context.WithTimeout(s.addLoggerToContext(context.Background(), nil), s.shutdownTimeout)
+		defer scancel()
 		for _, t := range s.transports {
-			if eerr := t.Shutdown(ctx); eerr != nil && err == nil {
+			if eerr := t.Shutdown(sctx); eerr != nil && err == nil {
 				err = eerr
 			}
 		}
@@ -83,6 +103,29 @@ func (s *Server) Run(ctx context.Context) error {
 	case err := <-exit:
 		return err
 	}
+	if stopped, err := s.drain(exit); stopped {
+		return err
+	}
 	stop <- nil
 	return <-exit
 }
+
+// drain notifies transports that the server is stopping, and waits for the pre-stop delay.
+// If a transport stops during the delay, its error is returned and stopped is true.
+func (s *Server) drain(exit chan error) (stopped bool, err error) {
+	for _, t := range s.transports {
+		if d, ok := t.(Drainer); ok {
+			d.Drain()
+		}
+	}
+	if s.preStopDelay <= 0 {
+		return false, nil
+	}
+	_ = s.logger.Log("msg", "draining", "delay", s.preStopDelay)
+	select {
+	case <-time.After(s.preStopDelay):
+		return false, nil
+	case err := <-exit:
+		return true, err
+	}
+}
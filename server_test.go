@@ -171,3 +171,66 @@ func TestStartError(t *testing.T) {
 	case <-wt.running:
 	}
 }
+
+func TestDrain(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.TODO())
+	exitError := make(chan error)
+	tr := NewHTTPTransport(Config{HTTPPort: 8086}).
+		Endpoint("POST", "/foo", testEP, Decoder(goodDecoder))
+	srv := NewServer(tr).PreStopDelay(300 * time.Millisecond).ShutdownTimeout(time.Second)
+	go func() {
+		exitError <- srv.Run(ctx)
+	}()
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+
+	start := time.Now()
+	for {
+		resp, err := client.Get("http://localhost:8086/readyz")
+		if err == nil && resp.StatusCode == http.StatusOK {
+			resp.Body.Close()
+			break
+		}
+		if time.Since(start) > 500*time.Millisecond {
+			t.Fatal("server did not start within 500msec or readiness returned an error")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	stopped := time.Now()
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	{
+		resp, err := client.Get("http://localhost:8086/readyz")
+		if err != nil {
+			t.Errorf("the server should still accept requests during the pre-stop delay : %s", err)
+		} else {
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("readiness should return a 503 status while draining, not %d", resp.StatusCode)
+			}
+		}
+	}
+	{
+		resp, err := client.Post("http://localhost:8086/foo", "application/json", bytes.NewBufferString(`{"foo":"bar"}`))
+		if err != nil {
+			t.Errorf("the server should still serve endpoints during the pre-stop delay : %s", err)
+		} else {
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("receive a %d status instead of 200", resp.StatusCode)
+			}
+		}
+	}
+
+	select {
+	case <-time.After(time.Second):
+		t.Error("Server.Run has not stopped after 1sec")
+	case err := <-exitError:
+		if err != nil {
+			t.Errorf("Server.Run returned an error : %s", err)
+		}
+		if time.Since(stopped) < 300*time.Millisecond {
+			t.Error("Server.Run should have waited for the pre-stop delay")
+		}
+	}
+}
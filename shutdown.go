@@ -1,7 +1,26 @@
 package kitty
 
+import "time"
+
 // Shutdown registers functions to be called when the server is stopped.
 func (s *Server) Shutdown(fns ...func()) *Server {
 	s.shutdown = fns
 	return s
 }
+
+// DefaultShutdownTimeout is the default maximum duration of the shutdown of transports.
+const DefaultShutdownTimeout = 30 * time.Second
+
+// PreStopDelay defines how long the server waits, once stopping, before shutting down transports (default: 0).
+// During this delay, transports are draining (e.g. the readiness handler of HTTPTransport returns a 503 status),
+// so that load balancers stop sending new requests before listeners are closed.
+func (s *Server) PreStopDelay(d time.Duration) *Server {
+	s.preStopDelay = d
+	return s
+}
+
+// ShutdownTimeout defines the maximum duration of the shutdown of transports (default: 30s).
+func (s *Server) ShutdownTimeout(d time.Duration) *Server {
+	s.shutdownTimeout = d
+	return s
+}
@@ -0,0 +1,135 @@
+package kitty
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	kithttp "github.com/go-kit/kit/transport/http"
+)
+
+// statusError is an error with a HTTP status code.
+type statusError struct {
+	error
+	code int
+}
+
+var _ Retryabler = statusError{}
+var _ kithttp.StatusCoder = statusError{}
+
+func (e statusError) Cause() error {
+	return e.error
+}
+
+// Unwrap returns the wrapped error (see errors.As).
+func (e statusError) Unwrap() error {
+	return e.error
+}
+
+func (e statusError) StatusCode() int {
+	return e.code
+}
+
+// Retryable returns true for 429 & 5XX errors.
+func (e statusError) Retryable() bool {
+	return httpError(e.code).Retryable()
+}
+
+// Headers forwards the headers of the wrapped error.
+func (e statusError) Headers() http.Header {
+	return ErrorHeaders(e.error)
+}
+
+// WithStatus defines the HTTP status code of an error.
+// 429 & 5XX errors are retryable, other errors are not.
+func WithStatus(err error, code int) error {
+	return statusError{error: err, code: code}
+}
+
+// BadRequest defines an error as a 400 Bad Request error.
+func BadRequest(err error) error {
+	return WithStatus(err, http.StatusBadRequest)
+}
+
+// Unauthorized defines an error as a 401 Unauthorized error.
+func Unauthorized(err error) error {
+	return WithStatus(err, http.StatusUnauthorized)
+}
+
+// Forbidden defines an error as a 403 Forbidden error.
+func Forbidden(err error) error {
+	return WithStatus(err, http.StatusForbidden)
+}
+
+// NotFound defines an error as a 404 Not Found error.
+func NotFound(err error) error {
+	return WithStatus(err, http.StatusNotFound)
+}
+
+// Conflict defines an error as a 409 Conflict error.
+func Conflict(err error) error {
+	return WithStatus(err, http.StatusConflict)
+}
+
+// PreconditionFailed defines an error as a 412 Precondition Failed error.
+func PreconditionFailed(err error) error {
+	return WithStatus(err, http.StatusPreconditionFailed)
+}
+
+// TooManyRequests defines an error as a retryable 429 Too Many Requests error.
+func TooManyRequests(err error) error {
+	return WithStatus(err, http.StatusTooManyRequests)
+}
+
+// Internal defines an error as a retryable 500 Internal Server Error error.
+func Internal(err error) error {
+	return WithStatus(err, http.StatusInternalServerError)
+}
+
+// Unavailable defines an error as a retryable 503 Service Unavailable error.
+func Unavailable(err error) error {
+	return WithStatus(err, http.StatusServiceUnavailable)
+}
+
+// headerError is an error with HTTP headers.
+type headerError struct {
+	error
+	header http.Header
+}
+
+var _ kithttp.Headerer = headerError{}
+
+func (e headerError) Cause() error {
+	return e.error
+}
+
+// Unwrap returns the wrapped error (see errors.As).
+func (e headerError) Unwrap() error {
+	return e.error
+}
+
+// StatusCode forwards the status code of the wrapped error.
+func (e headerError) StatusCode() int {
+	return ErrorStatusCode(e.error)
+}
+
+// Headers returns the headers of the error, and of the wrapped error.
+func (e headerError) Headers() http.Header {
+	h := ErrorHeaders(e.error)
+	for k, v := range e.header {
+		h[k] = v
+	}
+	return h
+}
+
+func (headerError) forwardsStatusCode() {}
+
+// WithRetryAfter adds a Retry-After header to an error (e.g. a TooManyRequests or Unavailable error).
+// The delay is rounded up to the second, and is at least 1 second.
+func WithRetryAfter(err error, d time.Duration) error {
+	secs := int((d + time.Second - 1) / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	return headerError{error: err, header: http.Header{"Retry-After": []string{strconv.Itoa(secs)}}}
+}
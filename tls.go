@@ -0,0 +1,138 @@
+package kitty
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// tlsConfig builds the TLS configuration of the HTTP server.
+// If no certificate is configured, nil is returned.
+func tlsConfig(cfg Config) (*tls.Config, error) {
+	if cfg.TLSCertFile == "" && cfg.TLSKeyFile == "" {
+		return nil, nil
+	}
+	if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+		return nil, errors.New("both TLSCertFile and TLSKeyFile are required")
+	}
+	cr := &certReloader{certFile: cfg.TLSCertFile, keyFile: cfg.TLSKeyFile, interval: certCheckInterval}
+	if err := cr.reload(); err != nil {
+		return nil, err
+	}
+	c := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: cr.GetCertificate,
+	}
+	if cfg.TLSClientCAFile != "" {
+		pem, err := ioutil.ReadFile(cfg.TLSClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no valid certificate found in TLSClientCAFile")
+		}
+		c.ClientCAs = pool
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return c, nil
+}
+
+// certCheckInterval is the minimum interval between two checks of the modification time of the certificate files.
+var certCheckInterval = 10 * time.Second
+
+// certReloader loads a certificate, and loads it again when the certificate or key files are modified.
+// Files are checked at most once per interval, not on every TLS handshake.
+type certReloader struct {
+	certFile, keyFile string
+	interval          time.Duration
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+// If the files have been modified but the new certificate cannot be loaded, the previous certificate is used.
+func (cr *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.RLock()
+	due := time.Since(cr.lastCheck) >= cr.interval
+	cr.mu.RUnlock()
+	if due {
+		cr.check()
+	}
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	return cr.cert, nil
+}
+
+// check reloads the certificate if the files have been modified since the last check.
+func (cr *certReloader) check() {
+	cr.mu.Lock()
+	if time.Since(cr.lastCheck) < cr.interval {
+		// already checked by a concurrent handshake
+		cr.mu.Unlock()
+		return
+	}
+	cr.lastCheck = time.Now()
+	last := cr.modTime
+	cr.mu.Unlock()
+	if modTime, err := cr.lastModified(); err == nil && modTime.After(last) {
+		_ = cr.reload()
+	}
+}
+
+// reload loads the certificate.
+func (cr *certReloader) reload() error {
+	modTime, err := cr.lastModified()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return err
+	}
+	cr.mu.Lock()
+	cr.cert = &cert
+	cr.modTime = modTime
+	cr.lastCheck = time.Now()
+	cr.mu.Unlock()
+	return nil
+}
+
+// lastModified returns the last modification time of the certificate and key files.
+func (cr *certReloader) lastModified() (time.Time, error) {
+	var last time.Time
+	for _, f := range []string{cr.certFile, cr.keyFile} {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return last, err
+		}
+		if fi.ModTime().After(last) {
+			last = fi.ModTime()
+		}
+	}
+	return last, nil
+}
+
+// populateTLSContext adds the subject of the verified client certificate to the context.
+func populateTLSContext(ctx context.Context, r *http.Request) context.Context {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, tlsClientSubjectKey, r.TLS.VerifiedChains[0][0].Subject.String())
+}
+
+// TLSClientSubject returns the subject of the verified client certificate (when using mutual TLS).
+// If the client did not present a verified certificate, an empty string is returned.
+func TLSClientSubject(ctx context.Context) string {
+	subject, _ := ctx.Value(tlsClientSubjectKey).(string)
+	return subject
+}
@@ -0,0 +1,180 @@
+package kitty
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTLS(t *testing.T) {
+	interval := certCheckInterval
+	certCheckInterval = 0
+	defer func() { certCheckInterval = interval }()
+	dir, err := ioutil.TempDir("", "kitty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCert(t, "ca", nil)
+	writeTestCert(t, dir, "ca", ca)
+	writeTestCert(t, dir, "server", newTestCert(t, "server-1", ca))
+	client := newTestCert(t, "client", ca)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	tr := NewHTTPTransport(Config{
+		HTTPPort:        8083,
+		TLSCertFile:     filepath.Join(dir, "server.crt"),
+		TLSKeyFile:      filepath.Join(dir, "server.key"),
+		TLSClientCAFile: filepath.Join(dir, "ca.crt"),
+	}).Endpoint("GET", "/subject", func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return TLSClientSubject(ctx), nil
+	})
+	go func() {
+		_ = NewServer(tr).Run(ctx)
+	}()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Leaf)
+	httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{*client},
+	}}}
+
+	start := time.Now()
+	for {
+		resp, err := httpClient.Get("https://localhost:8083/alivez")
+		if err == nil && resp.StatusCode == http.StatusOK {
+			resp.Body.Close()
+			break
+		}
+		if time.Since(start) > 500*time.Millisecond {
+			t.Fatal("server did not start within 500msec or liveness returned an error")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	{
+		resp, err := httpClient.Get("https://localhost:8083/subject")
+		if err != nil {
+			t.Fatalf("http.Get returned an error : %s", err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "\"CN=client\"\n" {
+			t.Errorf("the client certificate subject should be available to the endpoint, got %s", body)
+		}
+		if cn := resp.TLS.PeerCertificates[0].Subject.CommonName; cn != "server-1" {
+			t.Errorf("invalid server certificate %s", cn)
+		}
+	}
+	{
+		noCert := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+		if resp, err := noCert.Get("https://localhost:8083/subject"); err == nil {
+			resp.Body.Close()
+			t.Error("a client without certificate should be rejected")
+		}
+	}
+	{
+		writeTestCert(t, dir, "server", newTestCert(t, "server-2", ca))
+		future := time.Now().Add(time.Minute)
+		_ = os.Chtimes(filepath.Join(dir, "server.crt"), future, future)
+		httpClient.CloseIdleConnections()
+		resp, err := httpClient.Get("https://localhost:8083/alivez")
+		if err != nil {
+			t.Fatalf("http.Get returned an error : %s", err)
+		}
+		resp.Body.Close()
+		if cn := resp.TLS.PeerCertificates[0].Subject.CommonName; cn != "server-2" {
+			t.Errorf("the server certificate should have been reloaded, got %s", cn)
+		}
+	}
+}
+
+func TestCertReloaderInterval(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kitty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ca := newTestCert(t, "ca", nil)
+	writeTestCert(t, dir, "server", newTestCert(t, "server-1", ca))
+	cr := &certReloader{certFile: filepath.Join(dir, "server.crt"), keyFile: filepath.Join(dir, "server.key"), interval: time.Hour}
+	if err := cr.reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestCert(t, dir, "server", newTestCert(t, "server-2", ca))
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(filepath.Join(dir, "server.crt"), future, future)
+	cert, _ := cr.GetCertificate(nil)
+	if leaf, _ := x509.ParseCertificate(cert.Certificate[0]); leaf.Subject.CommonName != "server-1" {
+		t.Errorf("files should not be checked before the interval, got %s", leaf.Subject.CommonName)
+	}
+	cr.interval = 0
+	cert, _ = cr.GetCertificate(nil)
+	if leaf, _ := x509.ParseCertificate(cert.Certificate[0]); leaf.Subject.CommonName != "server-2" {
+		t.Errorf("the certificate should be reloaded after the interval, got %s", leaf.Subject.CommonName)
+	}
+}
+
+// newTestCert generates a certificate signed by parent (or self-signed if parent is nil).
+func newTestCert(t *testing.T, cn string, parent *tls.Certificate) *tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := tmpl, interface{}(key)
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// writeTestCert writes a certificate and its key as PEM files in dir.
+func writeTestCert(t *testing.T, dir, name string, cert *tls.Certificate) {
+	key, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+	if err := ioutil.WriteFile(filepath.Join(dir, name+".crt"), crt, 0600); err != nil {
+		t.Fatal(err)
+	}
+	k := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key})
+	if err := ioutil.WriteFile(filepath.Join(dir, name+".key"), k, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,22 @@
+package kitty
+
+import "context"
+
+// WithTraceContext adds the trace and span ids of the current span to the context, so that they can be logged
+// with the trace-id and span-id log keys (see Server.LogContext). It is meant to be used by tracing middlewares.
+func WithTraceContext(ctx context.Context, traceID, spanID string) context.Context {
+	ctx = context.WithValue(ctx, traceIDKey, traceID)
+	return context.WithValue(ctx, spanIDKey, spanID)
+}
+
+// TraceIDFromContext returns the trace id of the current span (an empty string if none is available).
+func TraceIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDKey).(string)
+	return id
+}
+
+// SpanIDFromContext returns the span id of the current span (an empty string if none is available).
+func SpanIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(spanIDKey).(string)
+	return id
+}
@@ -0,0 +1,108 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// TraceID is a W3C trace id.
+type TraceID [16]byte
+
+// String returns the hex encoded trace id.
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanID is a W3C span (or parent) id.
+type SpanID [8]byte
+
+// String returns the hex encoded span id.
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// sampledFlag is the sampled bit of the trace flags.
+const sampledFlag = 0x01
+
+// SpanContext holds the W3C trace context of a span.
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Flags      byte
+	TraceState string
+}
+
+// IsValid checks if the trace and span ids are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// IsSampled checks if the span is sampled, i.e. should be exported.
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&sampledFlag == sampledFlag
+}
+
+// Traceparent returns the value of the traceparent header (version 00).
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.Flags)
+}
+
+var errInvalidTraceparent = errors.New("invalid traceparent")
+
+// ParseTraceparent parses the value of a traceparent header.
+func ParseTraceparent(s string) (SpanContext, error) {
+	sc := SpanContext{}
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, errInvalidTraceparent
+	}
+	if _, err := hex.Decode(make([]byte, 1), []byte(parts[0])); err != nil {
+		return sc, errInvalidTraceparent
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, errInvalidTraceparent
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, errInvalidTraceparent
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, errInvalidTraceparent
+	}
+	flags := make([]byte, 1)
+	if _, err := hex.Decode(flags, []byte(parts[3])); err != nil {
+		return sc, errInvalidTraceparent
+	}
+	sc.Flags = flags[0]
+	if !sc.IsValid() {
+		return sc, errInvalidTraceparent
+	}
+	return sc, nil
+}
+
+// newTraceID generates a random trace id.
+func newTraceID() (id TraceID) {
+	_, _ = rand.Read(id[:])
+	return
+}
+
+// newSpanID generates a random span id.
+func newSpanID() (id SpanID) {
+	_, _ = rand.Read(id[:])
+	return
+}
+
+// newChild creates the context of a child span of parent.
+// If parent is not valid, a new trace is started, sampled according to sample.
+func newChild(parent SpanContext, sample Sampler) SpanContext {
+	if !parent.IsValid() {
+		sc := SpanContext{TraceID: newTraceID(), SpanID: newSpanID()}
+		if sample(sc.TraceID) {
+			sc.Flags = sampledFlag
+		}
+		return sc
+	}
+	return SpanContext{TraceID: parent.TraceID, SpanID: newSpanID(), Flags: parent.Flags, TraceState: parent.TraceState}
+}
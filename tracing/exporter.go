@@ -0,0 +1,67 @@
+package tracing
+
+import (
+	"sync"
+	"time"
+)
+
+// Span kinds.
+const (
+	// KindServer is the kind of spans created for incoming requests.
+	KindServer = "server"
+	// KindClient is the kind of spans created for outgoing requests.
+	KindClient = "client"
+)
+
+// Span is a finished span.
+type Span struct {
+	Name         string
+	Kind         string
+	SpanContext  SpanContext
+	ParentSpanID SpanID
+	Start        time.Time
+	End          time.Time
+	StatusCode   int
+	Error        string
+}
+
+// Exporter is the interface span exporters must implement.
+// Only sampled spans are exported.
+type Exporter interface {
+	// ExportSpan exports a finished span. It must not block.
+	ExportSpan(span *Span)
+}
+
+// InMemoryExporter keeps all exported spans in memory, and is mostly useful for tests.
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+var _ Exporter = &InMemoryExporter{}
+
+// NewInMemoryExporter creates an in-memory exporter.
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+// ExportSpan implements Exporter.
+func (e *InMemoryExporter) ExportSpan(span *Span) {
+	e.mu.Lock()
+	e.spans = append(e.spans, span)
+	e.mu.Unlock()
+}
+
+// Spans returns the list of exported spans.
+func (e *InMemoryExporter) Spans() []*Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*Span{}, e.spans...)
+}
+
+// Reset removes all exported spans.
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	e.spans = nil
+	e.mu.Unlock()
+}
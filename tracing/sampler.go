@@ -0,0 +1,35 @@
+package tracing
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Sampler decides if a new trace should be sampled.
+// It is only used when a trace is started : spans of an existing trace keep the sampled flag of their parent.
+type Sampler func(TraceID) bool
+
+// AlwaysSample samples all new traces.
+func AlwaysSample() Sampler {
+	return func(TraceID) bool { return true }
+}
+
+// NeverSample samples no new trace.
+func NeverSample() Sampler {
+	return func(TraceID) bool { return false }
+}
+
+// ProbabilitySampler samples a fraction p (between 0 and 1) of new traces.
+// The decision is based on the trace id, so that it is consistent for a given trace.
+func ProbabilitySampler(p float64) Sampler {
+	switch {
+	case p >= 1:
+		return AlwaysSample()
+	case p <= 0:
+		return NeverSample()
+	}
+	bound := uint64(p * math.MaxUint64)
+	return func(id TraceID) bool {
+		return binary.BigEndian.Uint64(id[8:]) < bound
+	}
+}
@@ -0,0 +1,150 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/kit/endpoint"
+	"github.com/objenious/kitty"
+)
+
+// W3C trace context headers.
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+type contextKey int
+
+const (
+	// context key for the context of the current span
+	currentKey contextKey = iota
+	// context key for the parent id of the server span, set by ExtractHTTP
+	parentKey
+)
+
+// FromContext returns the context of the current span.
+func FromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(currentKey).(SpanContext)
+	return sc, ok
+}
+
+// withCurrent sets the context of the current span.
+// Trace and span ids are also added as strings, so that they can be logged (see kitty.Server.LogContext).
+func withCurrent(ctx context.Context, sc SpanContext) context.Context {
+	ctx = context.WithValue(ctx, currentKey, sc)
+	return kitty.WithTraceContext(ctx, sc.TraceID.String(), sc.SpanID.String())
+}
+
+// ExtractHTTP extracts the trace context from the traceparent and tracestate headers of an incoming request,
+// and creates the context of the server span (a new, sampled, trace is started if the request has no valid traceparent).
+// It is meant to be used as a go-kit ServerBefore function of kitty.HTTPTransport:
+//
+//	t.Options(kithttp.ServerBefore(tracing.ExtractHTTP))
+//
+// Use Tracer.ExtractHTTP to sample new traces with the sampler of a tracer.
+func ExtractHTTP(ctx context.Context, r *http.Request) context.Context {
+	return extractHTTP(ctx, r, AlwaysSample())
+}
+
+func extractHTTP(ctx context.Context, r *http.Request, sample Sampler) context.Context {
+	parent, err := ParseTraceparent(r.Header.Get(TraceparentHeader))
+	if err == nil {
+		parent.TraceState = r.Header.Get(TracestateHeader)
+		ctx = context.WithValue(ctx, parentKey, parent.SpanID)
+	}
+	return withCurrent(ctx, newChild(parent, sample))
+}
+
+// InjectHTTP adds the traceparent and tracestate headers of the current span to an outgoing request.
+// It is meant to be used as a go-kit ClientBefore function of kitty.Client:
+//
+//	kitty.NewClient(method, u, enc, dec, kithttp.ClientBefore(tracing.InjectHTTP))
+func InjectHTTP(ctx context.Context, r *http.Request) context.Context {
+	sc, ok := FromContext(ctx)
+	if !ok || !sc.IsValid() {
+		return ctx
+	}
+	r.Header.Set(TraceparentHeader, sc.Traceparent())
+	if sc.TraceState != "" {
+		r.Header.Set(TracestateHeader, sc.TraceState)
+	}
+	return ctx
+}
+
+// Tracer creates spans, and exports them.
+type Tracer struct {
+	exporter Exporter
+	sampler  Sampler
+}
+
+// NewTracer creates a tracer, exporting spans to exp.
+// All new traces are sampled, unless a sampler is defined.
+func NewTracer(exp Exporter) *Tracer {
+	return &Tracer{exporter: exp, sampler: AlwaysSample()}
+}
+
+// Sampler defines the sampler used when the tracer starts a new trace (e.g. ProbabilitySampler(0.1)).
+// The sampled flag of incoming traces is always honoured.
+func (t *Tracer) Sampler(s Sampler) *Tracer {
+	t.sampler = s
+	return t
+}
+
+// ExtractHTTP is the same as the ExtractHTTP function, but new traces are sampled with the sampler of the tracer:
+//
+//	t.Options(kithttp.ServerBefore(tracer.ExtractHTTP))
+func (t *Tracer) ExtractHTTP(ctx context.Context, r *http.Request) context.Context {
+	return extractHTTP(ctx, r, t.sampler)
+}
+
+// Middleware creates a server middleware, recording a span per endpoint call, named after the route
+// (e.g. "GET /foo/{id}", see kitty.Route), with the resulting status code.
+// When used with ExtractHTTP, the span is a child of the span of the caller.
+func (t *Tracer) Middleware() endpoint.Middleware {
+	return func(e endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			sc, ok := FromContext(ctx)
+			parent, _ := ctx.Value(parentKey).(SpanID)
+			if !ok {
+				sc = newChild(SpanContext{}, t.sampler)
+				ctx = withCurrent(ctx, sc)
+			}
+			name := "endpoint"
+			if method, path := kitty.Route(ctx); path != "" {
+				name = method + " " + path
+			}
+			return t.record(ctx, e, request, &Span{Name: name, Kind: KindServer, SpanContext: sc, ParentSpanID: parent})
+		}
+	}
+}
+
+// ClientMiddleware creates a client middleware, recording a span named name per call.
+// When used with InjectHTTP, the span context is sent to the called service.
+func (t *Tracer) ClientMiddleware(name string) endpoint.Middleware {
+	return func(e endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			parent, _ := FromContext(ctx)
+			sc := newChild(parent, t.sampler)
+			ctx = withCurrent(ctx, sc)
+			return t.record(ctx, e, request, &Span{Name: name, Kind: KindClient, SpanContext: sc, ParentSpanID: parent.SpanID})
+		}
+	}
+}
+
+// record calls the endpoint, and exports the span if it is sampled.
+func (t *Tracer) record(ctx context.Context, e endpoint.Endpoint, request interface{}, span *Span) (interface{}, error) {
+	span.Start = time.Now()
+	response, err := e(ctx, request)
+	span.End = time.Now()
+	span.StatusCode = http.StatusOK
+	if err != nil {
+		span.StatusCode = kitty.ErrorStatusCode(err)
+		span.Error = err.Error()
+	}
+	if span.SpanContext.IsSampled() {
+		t.exporter.ExportSpan(span)
+	}
+	return response, err
+}
@@ -0,0 +1,147 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/go-kit/kit/endpoint"
+	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/objenious/kitty"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tcs := []struct {
+		header string
+		valid  bool
+	}{
+		{header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", valid: true},
+		{header: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-foo", valid: true},
+		{header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-foo", valid: false},
+		{header: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", valid: false},
+		{header: "00-00000000000000000000000000000000-00f067aa0ba902b7-01", valid: false},
+		{header: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", valid: false},
+		{header: "00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01", valid: false},
+		{header: "00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01", valid: false},
+		{header: "", valid: false},
+	}
+	for _, tc := range tcs {
+		sc, err := ParseTraceparent(tc.header)
+		if tc.valid != (err == nil) {
+			t.Errorf("ParseTraceparent(%s) returned %v", tc.header, err)
+		}
+		if !tc.valid {
+			continue
+		}
+		if expected := "00" + tc.header[2:55]; sc.Traceparent() != expected {
+			t.Errorf("Traceparent returned %s instead of %s", sc.Traceparent(), expected)
+		}
+	}
+}
+
+func TestTracing(t *testing.T) {
+	exp := NewInMemoryExporter()
+	tracer := NewTracer(exp)
+
+	var logTraceID, logSpanID string
+	tr := kitty.NewHTTPTransport(kitty.DefaultConfig).
+		Options(kithttp.ServerBefore(ExtractHTTP)).
+		Endpoint("GET", "/foo", func(ctx context.Context, _ interface{}) (interface{}, error) {
+			logTraceID, logSpanID = kitty.TraceIDFromContext(ctx), kitty.SpanIDFromContext(ctx)
+			return nil, errors.New("failure")
+		})
+	_ = tr.RegisterEndpoints(tracer.Middleware())
+	ts := httptest.NewServer(tr)
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL + "/foo")
+	e := kitty.NewClient("GET", u, kithttp.EncodeJSONRequest, func(context.Context, *http.Response) (interface{}, error) {
+		return nil, nil
+	}, kithttp.ClientBefore(InjectHTTP)).Endpoint()
+	e = tracer.ClientMiddleware("foo")(e)
+	_, _ = e(context.TODO(), nil)
+
+	spans := exp.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("2 spans should have been exported, got %d", len(spans))
+	}
+	server, client := spans[0], spans[1]
+	if server.Kind != KindServer || server.Name != "GET /foo" || server.StatusCode != http.StatusInternalServerError || server.Error != "failure" {
+		t.Errorf("invalid server span %+v", server)
+	}
+	if client.Kind != KindClient || client.Name != "foo" || client.StatusCode != http.StatusInternalServerError {
+		t.Errorf("invalid client span %+v", client)
+	}
+	if server.SpanContext.TraceID != client.SpanContext.TraceID {
+		t.Error("server and client spans should belong to the same trace")
+	}
+	if server.ParentSpanID != client.SpanContext.SpanID {
+		t.Error("the server span should be a child of the client span")
+	}
+	if logTraceID != server.SpanContext.TraceID.String() || logSpanID != server.SpanContext.SpanID.String() {
+		t.Errorf("trace and span ids should be available for logging, got %s/%s", logTraceID, logSpanID)
+	}
+}
+
+func TestUnsampled(t *testing.T) {
+	exp := NewInMemoryExporter()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	r.Header.Set(TracestateHeader, "foo=bar")
+	ctx := ExtractHTTP(context.TODO(), r)
+	sc, ok := FromContext(ctx)
+	if !ok || sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.TraceState != "foo=bar" || sc.IsSampled() {
+		t.Errorf("invalid extracted span context %+v", sc)
+	}
+	var ep endpoint.Endpoint = func(context.Context, interface{}) (interface{}, error) { return nil, nil }
+	_, _ = NewTracer(exp).Middleware()(ep)(ctx, nil)
+	if len(exp.Spans()) != 0 {
+		t.Error("unsampled spans should not be exported")
+	}
+}
+
+func TestSampler(t *testing.T) {
+	exp := NewInMemoryExporter()
+	tracer := NewTracer(exp).Sampler(NeverSample())
+	var ep endpoint.Endpoint = func(context.Context, interface{}) (interface{}, error) { return nil, nil }
+
+	ctx := tracer.ExtractHTTP(context.TODO(), httptest.NewRequest("GET", "/", nil))
+	if sc, _ := FromContext(ctx); !sc.IsValid() || sc.IsSampled() {
+		t.Errorf("a new trace should not be sampled, got %+v", sc)
+	}
+	_, _ = tracer.Middleware()(ep)(ctx, nil)
+	_, _ = tracer.ClientMiddleware("foo")(ep)(context.TODO(), nil)
+	if len(exp.Spans()) != 0 {
+		t.Error("new traces should not be sampled")
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	_, _ = tracer.Middleware()(ep)(tracer.ExtractHTTP(context.TODO(), r), nil)
+	if len(exp.Spans()) != 1 {
+		t.Error("the sampled flag of incoming traces should be honoured")
+	}
+}
+
+func TestProbabilitySampler(t *testing.T) {
+	s := ProbabilitySampler(0.25)
+	sampled := 0
+	for i := 0; i < 10000; i++ {
+		if s(newTraceID()) {
+			sampled++
+		}
+	}
+	if sampled < 2000 || sampled > 3000 {
+		t.Errorf("about 25%% of traces should be sampled, got %d/10000", sampled)
+	}
+	id := newTraceID()
+	if s(id) != s(id) {
+		t.Error("the sampling decision should be consistent for a trace")
+	}
+	if ProbabilitySampler(0)(id) || !ProbabilitySampler(1)(id) {
+		t.Error("invalid sampling decision for 0 or 1 probability")
+	}
+}
@@ -18,3 +18,10 @@ type Transport interface {
 	// Shutdown shutdowns the transport.
 	Shutdown(ctx context.Context) error
 }
+
+// Drainer is implemented by transports that need to be notified as soon as the server is stopping,
+// before the pre-stop delay (see Server.PreStopDelay) and before being shut down.
+type Drainer interface {
+	// Drain notifies the transport that it will soon be shut down.
+	Drain()
+}